@@ -13,6 +13,7 @@ import (
 	"github.com/roblesvargas97/estimago/internal/config"
 	"github.com/roblesvargas97/estimago/internal/db"
 	httpx "github.com/roblesvargas97/estimago/internal/http"
+	"github.com/roblesvargas97/estimago/internal/quotes"
 )
 
 func main() {
@@ -46,11 +47,16 @@ func main() {
 		}
 	}()
 
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	go quotes.RunExpirySweeper(jobsCtx, pool, time.Duration(cfg.QuoteExpirySweepMins)*time.Minute)
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
 	log.Println("🛑 Apagando servidor...")
+	stopJobs()
 
 	ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -0,0 +1,267 @@
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // accepted logo upload format
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/plans"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// maxLogoBytes bounds logo uploads; logos are embedded in every PDF.
+const maxLogoBytes = 512 << 10
+
+// maxLogoSide bounds logo dimensions, checked before decoding: a small file
+// can declare a huge image.
+const maxLogoSide = 2000
+
+var errLogoTooLarge = fmt.Errorf("logo must be at most %dx%d pixels", maxLogoSide, maxLogoSide)
+
+// GetSettings returns the caller's account settings.
+func GetSettings(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		s, err := Get(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, s)
+	}
+}
+
+// PatchSettings partially updates the caller's account settings.
+func PatchSettings(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var in UpdateSettingsIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		cols := []string{}
+		vals := []any{}
+
+		if in.FooterText != nil {
+			if !plans.FromRequest(r).Has(plans.FeatureBranding) {
+				utils.WriteErr(w, http.StatusForbidden, "feature_unavailable", "custom branding is not included in your plan")
+				return
+			}
+			footer, err := nullableString(*in.FooterText)
+			if err != nil {
+				utils.WriteErr(w, http.StatusBadRequest, "validation_error", "footer_text must be a string or null")
+				return
+			}
+			cols = append(cols, "footer_text")
+			vals = append(vals, footer)
+		}
+
+		if in.DefaultValidDays != nil {
+			if *in.DefaultValidDays < 1 || *in.DefaultValidDays > 365 {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "default_valid_days must be between 1 and 365")
+				return
+			}
+			cols = append(cols, "default_valid_days")
+			vals = append(vals, *in.DefaultValidDays)
+		}
+
+		if in.RoundingMode != nil {
+			mode := money.RoundingMode(strings.ToLower(strings.TrimSpace(*in.RoundingMode)))
+			if !mode.Valid() {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "rounding_mode must be one of half_up,half_even,up")
+				return
+			}
+			cols = append(cols, "rounding_mode")
+			vals = append(vals, mode)
+		}
+
+		if in.LaborRates != nil {
+			rates := map[string]money.Decimal{}
+			seen := map[string]bool{}
+			for role, rate := range *in.LaborRates {
+				role = strings.TrimSpace(role)
+				key := strings.ToLower(role)
+				if role == "" || seen[key] {
+					utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "labor_rates: role names must be non-empty and unique")
+					return
+				}
+				if rate.Sign() < 0 {
+					utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "labor_rates: rates must be >= 0")
+					return
+				}
+				seen[key] = true
+				rates[role] = rate
+			}
+			cols = append(cols, "labor_rates")
+			vals = append(vals, rates)
+		}
+
+		if len(cols) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		placeholders := make([]string, len(cols))
+		updates := make([]string, len(cols))
+		for i, c := range cols {
+			placeholders[i] = fmt.Sprintf("$%d", i+2)
+			updates[i] = c + "=EXCLUDED." + c
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO account_settings (owner_id, %s) VALUES ($1, %s)
+			ON CONFLICT (owner_id) DO UPDATE SET %s, updated_at=now()
+			RETURNING `+settingsColumns,
+			strings.Join(cols, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+
+		var s Settings
+		if err := scanSettings(pool.QueryRow(r.Context(), query, append([]any{ownerID}, vals...)...), &s); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, s)
+	}
+}
+
+// GetLogoHandler serves the caller's logo as image/jpeg.
+func GetLogoHandler(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		logo, err := GetLogo(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if logo == nil {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "logo not set")
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.WriteHeader(http.StatusOK)
+		w.Write(logo)
+	}
+}
+
+// PutLogo replaces the caller's logo. The body is the raw PNG or JPEG image;
+// it is flattened onto white and stored as JPEG so PDFs can embed it directly.
+func PutLogo(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureBranding) {
+			utils.WriteErr(w, http.StatusForbidden, "feature_unavailable", "custom branding is not included in your plan")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLogoBytes))
+		if err != nil {
+			utils.WriteErr(w, http.StatusRequestEntityTooLarge, "too_large", "logo must be at most 512KB")
+			return
+		}
+
+		logo, err := normalizeLogo(body)
+		if errors.Is(err, errLogoTooLarge) {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "logo must be a PNG or JPEG image")
+			return
+		}
+
+		if err := setLogo(r.Context(), pool, ownerID, logo); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteLogo removes the caller's logo.
+func DeleteLogo(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := setLogo(r.Context(), pool, ownerID, nil); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func normalizeLogo(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width > maxLogoSide || cfg.Height > maxLogoSide {
+		return nil, errLogoTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// nullableString decodes a JSON string or null, trimming whitespace.
+func nullableString(raw json.RawMessage) (*string, error) {
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+	return &s, nil
+}
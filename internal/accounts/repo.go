@@ -0,0 +1,46 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// settingsColumns lists the columns read into Settings, in scanSettings order.
+const settingsColumns = `footer_text, logo IS NOT NULL, default_valid_days, rounding_mode, labor_rates`
+
+func scanSettings(row pgx.Row, s *Settings) error {
+	return row.Scan(&s.FooterText, &s.HasLogo, &s.DefaultValidDays, &s.RoundingMode, &s.LaborRates)
+}
+
+// Get returns the owner's settings, or the defaults when none were saved.
+func Get(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID) (Settings, error) {
+	var s Settings
+	err := scanSettings(pool.QueryRow(ctx, `SELECT `+settingsColumns+` FROM account_settings WHERE owner_id=$1`, ownerID), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Defaults(), nil
+	}
+	return s, err
+}
+
+// GetLogo returns the owner's logo as JPEG bytes, or nil when none is set.
+func GetLogo(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID) ([]byte, error) {
+	var logo []byte
+	err := pool.QueryRow(ctx, `SELECT logo FROM account_settings WHERE owner_id=$1`, ownerID).Scan(&logo)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return logo, err
+}
+
+// setLogo stores (or clears, when logo is nil) the owner's logo.
+func setLogo(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID, logo []byte) error {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO account_settings (owner_id, logo) VALUES ($1, $2)
+		ON CONFLICT (owner_id) DO UPDATE SET logo = EXCLUDED.logo, updated_at = now()
+	`, ownerID, logo)
+	return err
+}
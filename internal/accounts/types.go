@@ -0,0 +1,40 @@
+package accounts
+
+import (
+	"encoding/json"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+// DefaultValidDays is how long a new quote stays valid unless the account
+// or the quote says otherwise.
+const DefaultValidDays = 30
+
+// Settings holds per-account preferences. Accounts without a stored row get
+// Defaults().
+type Settings struct {
+	FooterText       *string            `json:"footer_text"`
+	HasLogo          bool               `json:"has_logo"`
+	DefaultValidDays int                `json:"default_valid_days"`
+	RoundingMode     money.RoundingMode `json:"rounding_mode"`
+
+	// LaborRates is the default hourly rate per labor role, matched
+	// case-insensitively against quote labor lines.
+	LaborRates map[string]money.Decimal `json:"labor_rates"`
+}
+
+// Defaults returns the settings of an account that never saved any.
+func Defaults() Settings {
+	return Settings{
+		DefaultValidDays: DefaultValidDays,
+		RoundingMode:     money.RoundHalfUp,
+		LaborRates:       map[string]money.Decimal{},
+	}
+}
+
+type UpdateSettingsIn struct {
+	FooterText       *json.RawMessage          `json:"footer_text"`
+	DefaultValidDays *int                      `json:"default_valid_days"`
+	RoundingMode     *string                   `json:"rounding_mode"`
+	LaborRates       *map[string]money.Decimal `json:"labor_rates"` // replaces the whole map
+}
@@ -76,3 +76,18 @@ func PlanFromCtx(r *http.Request) (string, bool) {
 	plan, ok := v.(string)
 	return plan, ok
 }
+
+// RequireUserID returns the authenticated user ID or writes a 401 response.
+// Handlers mounted behind JWTMiddleware use it to scope queries to the caller:
+//
+//	ownerID, ok := auth.RequireUserID(w, r)
+//	if !ok {
+//	        return
+//	}
+func RequireUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, ok := UserIDFromCtx(r)
+	if !ok {
+		utils.WriteErr(w, http.StatusUnauthorized, "unauthorized", "invalid token")
+	}
+	return id, ok
+}
@@ -0,0 +1,309 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/units"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+func PostItem(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var in CreateItemIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		in.Name = strings.TrimSpace(in.Name)
+		if in.Name == "" {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name is required")
+			return
+		}
+		if in.Cost.Sign() < 0 || in.SellPrice.Sign() < 0 {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "cost and sell_price must be >= 0")
+			return
+		}
+		unit, err := units.Normalize(in.Unit)
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "unit: "+err.Error())
+			return
+		}
+
+		var it Item
+		err = scanItem(pool.QueryRow(r.Context(), `
+			INSERT INTO catalog_items (owner_id, sku, kind, name, unit, cost, sell_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+itemColumns,
+			ownerID, normSKU(in.SKU), strings.TrimSpace(in.Kind), in.Name, unit, in.Cost, in.SellPrice), &it)
+
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a catalog item with the same sku already exists")
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, it)
+	}
+}
+
+// ListItems returns the catalog ordered by name. "q" searches name and SKU;
+// "kind" filters by kind. Paginated like ListClients.
+func ListItems(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		page, _ := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("page"), "1"))
+		if page <= 0 {
+			page = 1
+		}
+
+		limit, _ := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("limit"), "50"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		where := ` WHERE owner_id = $1`
+		args := []any{ownerID}
+
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			args = append(args, q)
+			where += fmt.Sprintf(` AND (name ILIKE '%%' || $%d || '%%' OR sku ILIKE '%%' || $%d || '%%')`, len(args), len(args))
+		}
+
+		if kind := strings.TrimSpace(r.URL.Query().Get("kind")); kind != "" {
+			args = append(args, kind)
+			where += fmt.Sprintf(` AND kind = $%d`, len(args))
+		}
+
+		var total int
+		if err := pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM catalog_items`+where, args...).Scan(&total); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		sql := `SELECT ` + itemColumns + ` FROM catalog_items` + where
+		sql += ` ORDER BY name, id LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+		args = append(args, limit, (page-1)*limit)
+
+		rows, err := pool.Query(r.Context(), sql, args...)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []Item{}
+		for rows.Next() {
+			var it Item
+			if err := scanItem(rows, &it); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, it)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		utils.WriteJSON(w, http.StatusOK, outs)
+	}
+}
+
+func GetItem(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var it Item
+		err = scanItem(pool.QueryRow(r.Context(), `SELECT `+itemColumns+` FROM catalog_items WHERE id=$1 AND owner_id=$2`, id, ownerID), &it)
+		if err != nil {
+			writeItemErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, it)
+	}
+}
+
+// PatchItem updates a catalog item. Quotes keep the values they copied.
+func PatchItem(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var in UpdateItemIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		sets := []string{}
+		args := []any{}
+		idx := 1
+
+		if in.SKU != nil {
+			sets = append(sets, fmt.Sprintf("sku=$%d", idx))
+			args = append(args, normSKU(in.SKU))
+			idx++
+		}
+
+		if in.Kind != nil {
+			sets = append(sets, fmt.Sprintf("kind=$%d", idx))
+			args = append(args, strings.TrimSpace(*in.Kind))
+			idx++
+		}
+
+		if in.Name != nil {
+			name := strings.TrimSpace(*in.Name)
+			if name == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("name=$%d", idx))
+			args = append(args, name)
+			idx++
+		}
+
+		if in.Unit != nil {
+			unit, err := units.Normalize(*in.Unit)
+			if err != nil {
+				// A unit saved before the registry may be sent back unchanged.
+				var stored string
+				serr := pool.QueryRow(r.Context(), `SELECT unit FROM catalog_items WHERE id=$1 AND owner_id=$2`, id, ownerID).Scan(&stored)
+				if serr != nil && !errors.Is(serr, pgx.ErrNoRows) {
+					utils.WriteErr(w, http.StatusInternalServerError, "db_error", serr.Error())
+					return
+				}
+				if serr != nil || stored != strings.TrimSpace(*in.Unit) {
+					utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "unit: "+err.Error())
+					return
+				}
+				unit = stored
+			}
+			sets = append(sets, fmt.Sprintf("unit=$%d", idx))
+			args = append(args, unit)
+			idx++
+		}
+
+		if in.Cost != nil {
+			if in.Cost.Sign() < 0 {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "cost must be >= 0")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("cost=$%d", idx))
+			args = append(args, *in.Cost)
+			idx++
+		}
+
+		if in.SellPrice != nil {
+			if in.SellPrice.Sign() < 0 {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "sell_price must be >= 0")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("sell_price=$%d", idx))
+			args = append(args, *in.SellPrice)
+			idx++
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		sets = append(sets, "updated_at=now()")
+		args = append(args, id, ownerID)
+
+		query := fmt.Sprintf(`UPDATE catalog_items SET %s WHERE id=$%d AND owner_id=$%d RETURNING `+itemColumns, strings.Join(sets, ", "), idx, idx+1)
+
+		var it Item
+		err = scanItem(pool.QueryRow(r.Context(), query, args...), &it)
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a catalog item with the same sku already exists")
+			return
+		}
+		if err != nil {
+			writeItemErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, it)
+	}
+}
+
+// DeleteItem removes a catalog item. Quote items that referenced it keep
+// their copied name, unit and price.
+func DeleteItem(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		tag, err := pool.Exec(r.Context(), `DELETE FROM catalog_items WHERE id=$1 AND owner_id=$2`, id, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "catalog item not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeItemErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "catalog item not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
@@ -0,0 +1,53 @@
+package catalog
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// itemColumns lists the columns read into an Item, in scanItem order.
+const itemColumns = `id, sku, kind, name, unit, cost, sell_price, created_at, updated_at`
+
+func scanItem(row pgx.Row, it *Item) error {
+	return row.Scan(&it.ID, &it.SKU, &it.Kind, &it.Name, &it.Unit, &it.Cost, &it.SellPrice, &it.CreatedAt, &it.UpdatedAt)
+}
+
+// GetMany returns the owner's catalog items with the given ids, keyed by id.
+// Unknown ids and other owners' items are simply absent from the map.
+func GetMany(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]Item, error) {
+	out := map[uuid.UUID]Item{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	rows, err := pool.Query(ctx, `SELECT `+itemColumns+` FROM catalog_items WHERE owner_id=$1 AND id = ANY($2)`, ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it Item
+		if err := scanItem(rows, &it); err != nil {
+			return nil, err
+		}
+		out[it.ID] = it
+	}
+	return out, rows.Err()
+}
+
+// normSKU trims a SKU; blank means none.
+func normSKU(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	if t == "" {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,41 @@
+package catalog
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+// Item is a product or service an account quotes repeatedly. Unit and
+// SellPrice are the defaults copied onto quote items that reference it.
+type Item struct {
+	ID        uuid.UUID     `json:"id"`
+	SKU       *string       `json:"sku"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Unit      string        `json:"unit"`
+	Cost      money.Decimal `json:"cost"`
+	SellPrice money.Decimal `json:"sell_price"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type CreateItemIn struct {
+	SKU       *string       `json:"sku"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Unit      string        `json:"unit"`
+	Cost      money.Decimal `json:"cost"`
+	SellPrice money.Decimal `json:"sell_price"`
+}
+
+// UpdateItemIn is a partial update; an empty sku removes it.
+type UpdateItemIn struct {
+	SKU       *string        `json:"sku"`
+	Kind      *string        `json:"kind"`
+	Name      *string        `json:"name"`
+	Unit      *string        `json:"unit"`
+	Cost      *money.Decimal `json:"cost"`
+	SellPrice *money.Decimal `json:"sell_price"`
+}
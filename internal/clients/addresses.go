@@ -0,0 +1,267 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// Addresses follow the same primary rules as contacts.
+
+func ListAddresses(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		exists, err := Exists(r.Context(), pool, ownerID, clientID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if !exists {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `
+		SELECT `+addressColumns+` FROM client_addresses
+		WHERE client_id=$1 ORDER BY is_primary DESC, created_at, id`, clientID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []Address{}
+		for rows.Next() {
+			var a Address
+			if err := scanAddress(rows, &a); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, a)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+
+	}
+
+}
+
+func PostAddress(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var in CreateAddressIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		in.Line1 = strings.TrimSpace(in.Line1)
+		if in.Line1 == "" {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "line1 is required")
+			return
+		}
+		for _, p := range []**string{&in.Label, &in.Line2, &in.City, &in.State, &in.PostalCode, &in.Country} {
+			*p = trimPtr(*p)
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := lockClient(r.Context(), tx, ownerID, clientID); err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		primary, err := takePrimary(r, tx, "client_addresses", clientID, in.IsPrimary)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		var a Address
+		err = scanAddress(tx.QueryRow(r.Context(), `
+		INSERT INTO client_addresses (client_id, label, line1, line2, city, state, postal_code, country, is_primary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+addressColumns, clientID, in.Label, in.Line1, in.Line2, in.City, in.State, in.PostalCode, in.Country, primary), &a)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, a)
+
+	}
+
+}
+
+func GetAddressHandler(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+		id, ok := parseID(w, r, "address_id")
+		if !ok {
+			return
+		}
+
+		a, err := GetAddress(r.Context(), pool, ownerID, clientID, id)
+		if err != nil {
+			writeSubErr(w, err, "address")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, a)
+
+	}
+
+}
+
+func PatchAddress(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+		id, ok := parseID(w, r, "address_id")
+		if !ok {
+			return
+		}
+
+		var in UpdateAddressIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		sets := []string{}
+		args := []any{id, clientID}
+		idx := 3
+
+		if in.Line1 != nil {
+			line1 := strings.TrimSpace(*in.Line1)
+			if line1 == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "line1 cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("line1=$%d", idx))
+			args = append(args, line1)
+			idx++
+		}
+
+		nullable, ok := nullableSets(w, []nullableField{
+			{"label", in.Label}, {"line2", in.Line2}, {"city", in.City},
+			{"state", in.State}, {"postal_code", in.PostalCode}, {"country", in.Country},
+		}, &args, &idx)
+		if !ok {
+			return
+		}
+		sets = append(sets, nullable...)
+
+		if in.IsPrimary != nil {
+			sets = append(sets, fmt.Sprintf("is_primary=$%d", idx))
+			args = append(args, *in.IsPrimary)
+			idx++
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := lockClient(r.Context(), tx, ownerID, clientID); err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		if in.IsPrimary != nil && *in.IsPrimary {
+			if err := clearPrimary(r.Context(), tx, "client_addresses", clientID); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+		}
+
+		var a Address
+		err = scanAddress(tx.QueryRow(r.Context(), `
+		UPDATE client_addresses SET `+strings.Join(sets, ", ")+`, updated_at=now()
+		WHERE id=$1 AND client_id=$2
+		RETURNING `+addressColumns, args...), &a)
+		if err != nil {
+			writeSubErr(w, err, "address")
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, a)
+
+	}
+
+}
+
+// DeleteAddress removes an address. Quotes for work there keep their
+// snapshot of the address.
+func DeleteAddress(pool *pgxpool.Pool) http.HandlerFunc {
+	return deleteSub(pool, "client_addresses", "address_id", "address")
+}
@@ -0,0 +1,389 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// A client's first contact becomes its primary one; marking another as
+// primary moves the flag, and deleting the primary contact passes it to the
+// oldest remaining one.
+
+func ListContacts(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		exists, err := Exists(r.Context(), pool, ownerID, clientID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if !exists {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `
+		SELECT `+contactColumns+` FROM client_contacts
+		WHERE client_id=$1 ORDER BY is_primary DESC, created_at, id`, clientID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []Contact{}
+		for rows.Next() {
+			var c Contact
+			if err := scanContact(rows, &c); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, c)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+
+	}
+
+}
+
+func PostContact(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var in CreateContactIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		in.Name = strings.TrimSpace(in.Name)
+		if in.Name == "" {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name is required")
+			return
+		}
+		in.Role, in.Email, in.Phone = trimPtr(in.Role), trimPtr(in.Email), trimPtr(in.Phone)
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := lockClient(r.Context(), tx, ownerID, clientID); err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		primary, err := takePrimary(r, tx, "client_contacts", clientID, in.IsPrimary)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		var c Contact
+		err = scanContact(tx.QueryRow(r.Context(), `
+		INSERT INTO client_contacts (client_id, name, role, email, phone, is_primary)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+contactColumns, clientID, in.Name, in.Role, in.Email, in.Phone, primary), &c)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, c)
+
+	}
+
+}
+
+func GetContactHandler(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+		id, ok := parseID(w, r, "contact_id")
+		if !ok {
+			return
+		}
+
+		c, err := GetContact(r.Context(), pool, ownerID, clientID, id)
+		if err != nil {
+			writeSubErr(w, err, "contact")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, c)
+
+	}
+
+}
+
+func PatchContact(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+		id, ok := parseID(w, r, "contact_id")
+		if !ok {
+			return
+		}
+
+		var in UpdateContactIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		sets := []string{}
+		args := []any{id, clientID}
+		idx := 3
+
+		if in.Name != nil {
+			name := strings.TrimSpace(*in.Name)
+			if name == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("name=$%d", idx))
+			args = append(args, name)
+			idx++
+		}
+
+		nullable, ok := nullableSets(w, []nullableField{{"role", in.Role}, {"email", in.Email}, {"phone", in.Phone}}, &args, &idx)
+		if !ok {
+			return
+		}
+		sets = append(sets, nullable...)
+
+		if in.IsPrimary != nil {
+			sets = append(sets, fmt.Sprintf("is_primary=$%d", idx))
+			args = append(args, *in.IsPrimary)
+			idx++
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := lockClient(r.Context(), tx, ownerID, clientID); err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		if in.IsPrimary != nil && *in.IsPrimary {
+			if err := clearPrimary(r.Context(), tx, "client_contacts", clientID); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+		}
+
+		var c Contact
+		err = scanContact(tx.QueryRow(r.Context(), `
+		UPDATE client_contacts SET `+strings.Join(sets, ", ")+`, updated_at=now()
+		WHERE id=$1 AND client_id=$2
+		RETURNING `+contactColumns, args...), &c)
+		if err != nil {
+			writeSubErr(w, err, "contact")
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, c)
+
+	}
+
+}
+
+// DeleteContact removes a contact. Quotes addressed to it keep their
+// snapshot of the contact.
+func DeleteContact(pool *pgxpool.Pool) http.HandlerFunc {
+	return deleteSub(pool, "client_contacts", "contact_id", "contact")
+}
+
+// parseID parses the uuid URL parameter name.
+func parseID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, name)))
+	if err != nil {
+		utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// takePrimary decides whether a new row in table is primary: when asked to
+// be, taking the flag from the current one, or when it is the client's
+// first.
+func takePrimary(r *http.Request, tx pgx.Tx, table string, clientID uuid.UUID, want bool) (bool, error) {
+	if want {
+		return true, clearPrimary(r.Context(), tx, table, clientID)
+	}
+	found, err := hasRows(r.Context(), tx, table, clientID)
+	return !found, err
+}
+
+type nullableField struct {
+	col string
+	raw *json.RawMessage
+}
+
+// nullableSets turns the present fields into SET clauses of a PATCH,
+// appending their values to args. On failure the error has been written and
+// ok is false.
+func nullableSets(w http.ResponseWriter, fields []nullableField, args *[]any, idx *int) ([]string, bool) {
+	sets := []string{}
+	for _, f := range fields {
+		if f.raw == nil {
+			continue
+		}
+		v, err := nullableString(*f.raw)
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", f.col+" must be a string or null")
+			return nil, false
+		}
+		sets = append(sets, fmt.Sprintf("%s=$%d", f.col, *idx))
+		*args = append(*args, v)
+		*idx++
+	}
+	return sets, true
+}
+
+// deleteSub deletes a contact or address, passing the primary flag on.
+func deleteSub(pool *pgxpool.Pool, table, param, what string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		clientID, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+		id, ok := parseID(w, r, param)
+		if !ok {
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := lockClient(r.Context(), tx, ownerID, clientID); err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		tag, err := tx.Exec(r.Context(), `DELETE FROM `+table+` WHERE id=$1 AND client_id=$2`, id, clientID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", what+" not found")
+			return
+		}
+
+		if err := promoteOldest(r.Context(), tx, table, clientID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	}
+
+}
+
+func writeSubErr(w http.ResponseWriter, err error, what string) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", what+" not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+// trimPtr trims an optional string.
+func trimPtr(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	return &t
+}
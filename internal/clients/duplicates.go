@@ -0,0 +1,195 @@
+package clients
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// ListDuplicates - Finds clients that are probably the same customer
+// Purpose: Surfaces candidates for merging that UNIQUE (name, email) lets through
+// Advantages:
+//   - Compares normalized keys: "ACME" and "Acme, Inc." share a name, "+52 (55) 1234-5678"
+//     and "5512345678" a phone, regardless of the other fields
+//   - Groups are transitive, so a client matching two others by different fields joins both
+//
+// Weaknesses:
+//   - Loads every client of the account; fine for the sizes the plans allow
+//   - No fuzzy matching: typos ("Acme" vs "Acmee") are not detected
+func ListDuplicates(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `SELECT `+clientColumns+` FROM clients WHERE owner_id=$1 ORDER BY created_at`, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		all := []Client{}
+		for rows.Next() {
+			var c Client
+			if err := scanClient(rows, &c); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			all = append(all, c)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, duplicateGroups(all))
+
+	}
+
+}
+
+// duplicateGroups groups clients sharing a normalized name, email or phone.
+func duplicateGroups(all []Client) []DuplicateGroup {
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// reasons[i] collects, per member, the kinds of key it was joined by.
+	reasons := make([]map[string]bool, len(all))
+	first := map[string]int{}
+	for i, c := range all {
+		reasons[i] = map[string]bool{}
+		for _, k := range []struct{ kind, key string }{
+			{"name", normName(c.Name)},
+			{"email", normEmail(c.Email)},
+			{"phone", normPhone(c.Phone)},
+		} {
+			if k.key == "" {
+				continue
+			}
+			j, seen := first[k.kind+":"+k.key]
+			if !seen {
+				first[k.kind+":"+k.key] = i
+				continue
+			}
+			reasons[i][k.kind], reasons[j][k.kind] = true, true
+			parent[find(i)] = find(j)
+		}
+	}
+
+	byRoot := map[int]*DuplicateGroup{}
+	why := map[int]map[string]bool{}
+	order := []int{}
+	for i, c := range all {
+		root := find(i)
+		g, ok := byRoot[root]
+		if !ok {
+			g = &DuplicateGroup{Clients: []Client{}}
+			byRoot[root], why[root] = g, map[string]bool{}
+			order = append(order, root)
+		}
+		g.Clients = append(g.Clients, c)
+		for k := range reasons[i] {
+			why[root][k] = true
+		}
+	}
+
+	out := []DuplicateGroup{}
+	for _, root := range order {
+		g := byRoot[root]
+		if len(g.Clients) < 2 {
+			continue
+		}
+		for _, k := range []string{"name", "email", "phone"} {
+			if why[root][k] {
+				g.Reasons = append(g.Reasons, k)
+			}
+		}
+		out = append(out, *g)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Clients) > len(out[j].Clients) })
+	return out
+}
+
+var foldAccents = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c",
+)
+
+// legalSuffixes are company-type words ignored when comparing names.
+var legalSuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "ltd": true, "limited": true, "corp": true,
+	"corporation": true, "co": true, "company": true, "plc": true, "gmbh": true,
+	"sa": true, "sas": true, "sapi": true, "srl": true, "de": true, "cv": true, "rl": true,
+}
+
+// normName lowercases a name, folds accents, drops punctuation and
+// company-type suffixes ("Acme, S.A. de C.V." -> "acme").
+func normName(s string) string {
+	s = foldAccents.Replace(strings.ToLower(s))
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r):
+			return r
+		case r == '&':
+			return ' '
+		}
+		return -1 // "S.A." -> "sa"
+	}, s)
+
+	words := strings.Fields(s)
+	for len(words) > 1 && legalSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func normEmail(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(*s))
+}
+
+// normPhone keeps the last 10 digits, dropping country codes and formatting.
+// Numbers with fewer than 7 digits are ignored.
+func normPhone(s *string) string {
+	if s == nil {
+		return ""
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, *s)
+	if len(digits) < 7 {
+		return ""
+	}
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
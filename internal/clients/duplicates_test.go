@@ -0,0 +1,106 @@
+package clients
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Acme, S.A. de C.V.", "acme"},
+		{"ACME Inc.", "acme"},
+		{"acme", "acme"},
+		{"Jardines García S.A.P.I. de C.V.", "jardines garcia"},
+		{"Constructora Pérez & Hijos", "constructora perez hijos"},
+		{"  Ñandú   Co ", "nandu"},
+		{"ÉXITO LLC", "exito"},
+		{"De La Cruz", "de la cruz"},
+		{"Company Co", "company"},
+		{"Inc", "inc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normName(tt.in); got != tt.want {
+			t.Errorf("normName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormPhone(t *testing.T) {
+	tests := []struct {
+		in   *string
+		want string
+	}{
+		{nil, ""},
+		{ptr("+52 (55) 1234-5678"), "5512345678"},
+		{ptr("55 1234 5678"), "5512345678"},
+		{ptr("+1 (555) 010-0199"), "5550100199"},
+		{ptr("123 4567"), "1234567"},
+		{ptr("123-456"), ""},
+		{ptr("ext. 12"), ""},
+		{ptr(""), ""},
+	}
+	for _, tt := range tests {
+		if got := normPhone(tt.in); got != tt.want {
+			t.Errorf("normPhone(%q) = %q, want %q", deref(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	all := []Client{
+		{Name: "Acme, S.A. de C.V.", Email: ptr("ventas@acme.mx")},
+		{Name: "ACME Inc", Phone: ptr("+52 55 1234 5678")},
+		{Name: "Beta", Phone: ptr("5512345678"), Email: ptr("x@beta.com")},
+		{Name: "Delta"},
+		{Name: "Gamma", Email: ptr(" X@Beta.com ")},
+		{Name: "Omega", Email: ptr("o@omega.com")},
+		{Name: "Epsilon", Phone: ptr("12345")},
+		{Name: "Zeta", Phone: ptr("12345")},
+		{Name: "omega"},
+	}
+
+	got := duplicateGroups(all)
+	if len(got) != 2 {
+		t.Fatalf("duplicateGroups = %d groups, want 2: %+v", len(got), got)
+	}
+
+	// Acme and ACME share a name, ACME and Beta a phone, Beta and Gamma an
+	// email: one group, largest first, members in input order.
+	if names := groupNames(got[0]); !slices.Equal(names, []string{"Acme, S.A. de C.V.", "ACME Inc", "Beta", "Gamma"}) {
+		t.Errorf("group 0 = %q", names)
+	}
+	if !slices.Equal(got[0].Reasons, []string{"name", "email", "phone"}) {
+		t.Errorf("group 0 reasons = %q, want [name email phone]", got[0].Reasons)
+	}
+
+	if names := groupNames(got[1]); !slices.Equal(names, []string{"Omega", "omega"}) {
+		t.Errorf("group 1 = %q", names)
+	}
+	if !slices.Equal(got[1].Reasons, []string{"name"}) {
+		t.Errorf("group 1 reasons = %q, want [name]", got[1].Reasons)
+	}
+}
+
+func TestDuplicateGroupsNone(t *testing.T) {
+	got := duplicateGroups([]Client{{Name: "Acme"}, {Name: "Beta"}})
+	if got == nil || len(got) != 0 {
+		t.Errorf("duplicateGroups = %#v, want an empty slice", got)
+	}
+	if got := duplicateGroups(nil); got == nil || len(got) != 0 {
+		t.Errorf("duplicateGroups(nil) = %#v, want an empty slice", got)
+	}
+}
+
+func groupNames(g DuplicateGroup) []string {
+	names := []string{}
+	for _, c := range g.Clients {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func ptr(s string) *string { return &s }
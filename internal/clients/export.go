@@ -0,0 +1,133 @@
+package clients
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// ExportClients - Downloads the account's clients as CSV or vCard
+// Purpose: Lets users take their customer list elsewhere, or back up before a bulk import
+// Advantages:
+//   - ?format=csv (default) or vcf; ?archived= filters like the list endpoint
+//   - The CSV's name, email and phone columns re-import without a mapping
+//
+// Weaknesses:
+//   - Contacts and addresses are not exported
+//   - meta is a single JSON column in the CSV and is left out of vCards
+func ExportClients(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		format := strings.ToLower(utils.DefaultIfEmpty(strings.TrimSpace(r.URL.Query().Get("format")), "csv"))
+		if format != "csv" && format != "vcf" {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "format must be one of csv,vcf")
+			return
+		}
+
+		archived, ok := archivedFilter(w, r)
+		if !ok {
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `SELECT `+clientColumns+` FROM clients WHERE owner_id=$1`+archived+` ORDER BY name, created_at`, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		all := []Client{}
+		for rows.Next() {
+			var c Client
+			if err := scanClient(rows, &c); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			all = append(all, c)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if format == "vcf" {
+			w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="clients.vcf"`)
+			w.WriteHeader(http.StatusOK)
+			for _, c := range all {
+				fmt.Fprint(w, vcard(c))
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="clients.csv"`)
+		w.WriteHeader(http.StatusOK)
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "email", "phone", "tags", "meta", "created_at"})
+		for _, c := range all {
+			cw.Write([]string{csvCell(c.Name), csvCell(deref(c.Email)), csvCell(deref(c.Phone)),
+				csvCell(strings.Join(c.Tags, ";")), csvCell(string(c.Meta)), c.CreatedAt.Format(time.RFC3339)})
+		}
+		cw.Flush()
+
+	}
+
+}
+
+// csvCell keeps a spreadsheet from running a value as a formula: cells
+// starting with = + - @, a tab or a CR get a leading '. Imports strip it.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune(formulaStart, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+const formulaStart = "=+-@\t\r"
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+// vcard renders a client as a vCard 3.0.
+func vcard(c Client) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	b.WriteString("FN:" + vcardEscaper.Replace(c.Name) + "\r\n")
+	b.WriteString("N:" + vcardEscaper.Replace(c.Name) + ";;;;\r\n")
+	if c.Email != nil {
+		b.WriteString("EMAIL;TYPE=INTERNET:" + vcardEscaper.Replace(*c.Email) + "\r\n")
+	}
+	if c.Phone != nil {
+		b.WriteString("TEL:" + vcardEscaper.Replace(*c.Phone) + "\r\n")
+	}
+	if len(c.Tags) > 0 {
+		tags := make([]string, len(c.Tags))
+		for i, t := range c.Tags {
+			tags[i] = vcardEscaper.Replace(t)
+		}
+		b.WriteString("CATEGORIES:" + strings.Join(tags, ",") + "\r\n")
+	}
+	b.WriteString("END:VCARD\r\n")
+	return b.String()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
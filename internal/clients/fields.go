@@ -0,0 +1,443 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// Custom fields give keys of clients.meta a type. Values of defined keys
+// are validated and normalized whenever meta is written; removing a field
+// or an enum option leaves the stored values alone.
+
+const (
+	maxTags   = 30
+	maxTagLen = 50
+)
+
+var fieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+const fieldColumns = `id, key, label, type, options, required, created_at, updated_at`
+
+func scanField(row pgx.Row, f *Field) error {
+	return row.Scan(&f.ID, &f.Key, &f.Label, &f.Type, &f.Options, &f.Required, &f.CreatedAt, &f.UpdatedAt)
+}
+
+func PostField(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var in CreateFieldIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		in.Key = strings.TrimSpace(in.Key)
+		if !fieldKey.MatchString(in.Key) {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "key must be lowercase letters, digits and _, starting with a letter")
+			return
+		}
+		in.Label = strings.TrimSpace(in.Label)
+		if in.Label == "" {
+			in.Label = in.Key
+		}
+		in.Type = strings.ToLower(strings.TrimSpace(in.Type))
+		switch in.Type {
+		case FieldText, FieldNumber, FieldDate, FieldEnum:
+		default:
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "type must be one of text,number,date,enum")
+			return
+		}
+		options, err := fieldOptions(in.Type, in.Options)
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return
+		}
+
+		var f Field
+		err = scanField(pool.QueryRow(r.Context(), `
+		INSERT INTO client_fields (owner_id, key, label, type, options, required)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+fieldColumns, ownerID, in.Key, in.Label, in.Type, options, in.Required), &f)
+
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a field with the same key already exists")
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, f)
+
+	}
+
+}
+
+func ListFields(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		schema, err := loadSchema(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		outs := []Field{}
+		for _, f := range schema {
+			outs = append(outs, f)
+		}
+		sort.Slice(outs, func(i, j int) bool { return outs[i].Key < outs[j].Key })
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+
+	}
+
+}
+
+func GetField(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var f Field
+		err := scanField(pool.QueryRow(r.Context(), `SELECT `+fieldColumns+` FROM client_fields WHERE id=$1 AND owner_id=$2`, id, ownerID), &f)
+		if err != nil {
+			writeSubErr(w, err, "field")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, f)
+
+	}
+
+}
+
+// PatchField updates a field's label, enum options or required flag.
+// Making a field required does not touch existing clients; it is enforced
+// the next time their meta is replaced.
+func PatchField(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var in UpdateFieldIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		var f Field
+		err := scanField(pool.QueryRow(r.Context(), `SELECT `+fieldColumns+` FROM client_fields WHERE id=$1 AND owner_id=$2`, id, ownerID), &f)
+		if err != nil {
+			writeSubErr(w, err, "field")
+			return
+		}
+
+		sets := []string{}
+		args := []any{id, ownerID}
+		idx := 3
+
+		if in.Label != nil {
+			label := strings.TrimSpace(*in.Label)
+			if label == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "label cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("label=$%d", idx))
+			args = append(args, label)
+			idx++
+		}
+
+		if in.Options != nil {
+			options, err := fieldOptions(f.Type, *in.Options)
+			if err != nil {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+				return
+			}
+			sets = append(sets, fmt.Sprintf("options=$%d", idx))
+			args = append(args, options)
+			idx++
+		}
+
+		if in.Required != nil {
+			sets = append(sets, fmt.Sprintf("required=$%d", idx))
+			args = append(args, *in.Required)
+			idx++
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		err = scanField(pool.QueryRow(r.Context(), `
+		UPDATE client_fields SET `+strings.Join(sets, ", ")+`, updated_at=now()
+		WHERE id=$1 AND owner_id=$2
+		RETURNING `+fieldColumns, args...), &f)
+		if err != nil {
+			writeSubErr(w, err, "field")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, f)
+
+	}
+
+}
+
+// DeleteField removes a field definition. Clients keep their values, which
+// become free-form meta.
+func DeleteField(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		tag, err := pool.Exec(r.Context(), `DELETE FROM client_fields WHERE id=$1 AND owner_id=$2`, id, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "field not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	}
+
+}
+
+// fieldOptions validates the options of a field of type typ: a non-empty
+// list of distinct values for enums, nothing for the other types.
+func fieldOptions(typ string, in []string) ([]string, error) {
+	if typ != FieldEnum {
+		if len(in) > 0 {
+			return nil, errors.New("options are only allowed for enum fields")
+		}
+		return []string{}, nil
+	}
+
+	out := []string{}
+	for _, o := range in {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			return nil, errors.New("options cannot be empty")
+		}
+		if slices.Contains(out, o) {
+			return nil, fmt.Errorf("option %q is repeated", o)
+		}
+		out = append(out, o)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("enum fields need at least one option")
+	}
+	return out, nil
+}
+
+// fieldSchema is an account's custom fields by key.
+type fieldSchema map[string]Field
+
+func loadSchema(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID) (fieldSchema, error) {
+	rows, err := pool.Query(ctx, `SELECT `+fieldColumns+` FROM client_fields WHERE owner_id=$1`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := fieldSchema{}
+	for rows.Next() {
+		var f Field
+		if err := scanField(rows, &f); err != nil {
+			return nil, err
+		}
+		schema[f.Key] = f
+	}
+	return schema, rows.Err()
+}
+
+// check validates and normalizes the values of defined fields in meta. A
+// partial meta (a PATCH merge) may leave out required fields, but cannot
+// clear them with null.
+func (s fieldSchema) check(meta map[string]json.RawMessage, partial bool) (map[string]json.RawMessage, error) {
+	out := make(map[string]json.RawMessage, len(meta))
+	for k, raw := range meta {
+		f, ok := s[k]
+		if !ok || string(raw) == "null" {
+			if ok && f.Required {
+				return nil, fmt.Errorf("meta.%s is required", k)
+			}
+			out[k] = raw
+			continue
+		}
+		v, err := f.value(raw)
+		if err != nil {
+			return nil, fmt.Errorf("meta.%s: %w", k, err)
+		}
+		out[k] = v
+	}
+
+	if !partial {
+		for k, f := range s {
+			if _, ok := out[k]; f.Required && !ok {
+				return nil, fmt.Errorf("meta.%s is required", k)
+			}
+		}
+	}
+	return out, nil
+}
+
+// value validates a JSON value for the field. Numbers may also be given as
+// strings, as CSV files and query strings carry them.
+func (f Field) value(raw json.RawMessage) (json.RawMessage, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		if f.Type != FieldNumber {
+			return nil, fmt.Errorf("must be a string")
+		}
+		s = string(raw)
+	}
+	return f.parse(s)
+}
+
+// parse turns the text form of a value into its stored JSON.
+func (f Field) parse(s string) (json.RawMessage, error) {
+	s = strings.TrimSpace(s)
+	switch f.Type {
+	case FieldNumber:
+		d, err := money.Parse(s)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return json.RawMessage(d.Trim().String()), nil
+	case FieldDate:
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return nil, errors.New("must be a date (YYYY-MM-DD)")
+		}
+		s = t.Format(time.DateOnly)
+	case FieldEnum:
+		if !slices.Contains(f.Options, s) {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(f.Options, ","))
+		}
+	default:
+		if s == "" && f.Required {
+			return nil, errors.New("cannot be empty")
+		}
+	}
+	return json.Marshal(s)
+}
+
+// normTags trims, lowercases and deduplicates tags.
+func normTags(in []string) ([]string, error) {
+	out := []string{}
+	for _, t := range in {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			return nil, errors.New("tags cannot be empty")
+		}
+		if len(t) > maxTagLen {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", t, maxTagLen)
+		}
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("a client can have at most %d tags", maxTags)
+	}
+	return out, nil
+}
+
+// metaFilter builds the meta containment object of the meta.<key> query
+// parameters. Values of custom fields are parsed as the field's type, so
+// meta.size=10 finds the number 10; other keys match strings.
+func metaFilter(r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID) (map[string]json.RawMessage, error) {
+	params := map[string]string{}
+	for p, vs := range r.URL.Query() {
+		if key, ok := strings.CutPrefix(p, "meta."); ok {
+			if key == "" {
+				return nil, errors.New("meta filters need a key, as in meta.city=")
+			}
+			params[key] = vs[0]
+		}
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	schema, err := loadSchema(r.Context(), pool, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return schema.filter(params)
+}
+
+// filter turns meta.<key> query values into the JSON values to match.
+func (s fieldSchema) filter(params map[string]string) (map[string]json.RawMessage, error) {
+	out := make(map[string]json.RawMessage, len(params))
+	for key, v := range params {
+		f, ok := s[key]
+		if !ok {
+			out[key], _ = json.Marshal(v)
+			continue
+		}
+		raw, err := f.parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("meta.%s: %w", key, err)
+		}
+		out[key] = raw
+	}
+	return out, nil
+}
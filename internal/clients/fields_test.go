@@ -0,0 +1,200 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var testSchema = fieldSchema{
+	"size":  {Key: "size", Type: FieldNumber},
+	"since": {Key: "since", Type: FieldDate},
+	"tier":  {Key: "tier", Type: FieldEnum, Options: []string{"gold", "silver"}, Required: true},
+	"note":  {Key: "note", Type: FieldText},
+}
+
+func TestSchemaCheck(t *testing.T) {
+	tests := []struct {
+		meta    string
+		partial bool
+		want    map[string]string
+	}{
+		{`{"size": "10.50"}`, true, map[string]string{"size": `10.5`}},
+		{`{"size": 10.50}`, true, map[string]string{"size": `10.5`}},
+		{`{"size": 1e2}`, true, map[string]string{"size": `100`}},
+		{`{"size": " 7 "}`, true, map[string]string{"size": `7`}},
+		{`{"size": null}`, true, map[string]string{"size": `null`}},
+		{`{"since": "2024-02-29"}`, true, map[string]string{"since": `"2024-02-29"`}},
+		{`{"tier": " gold "}`, true, map[string]string{"tier": `"gold"`}},
+		{`{"note": ""}`, true, map[string]string{"note": `""`}},
+		{`{"city": "Monterrey", "n": 3}`, true, map[string]string{"city": `"Monterrey"`, "n": `3`}},
+		{`{"tier": "silver", "size": "2"}`, false, map[string]string{"tier": `"silver"`, "size": `2`}},
+	}
+	for _, tt := range tests {
+		var meta map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(tt.meta), &meta); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := testSchema.check(meta, tt.partial)
+		if err != nil {
+			t.Errorf("check(%s, %v): %v", tt.meta, tt.partial, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("check(%s, %v) = %d keys, want %d", tt.meta, tt.partial, len(got), len(tt.want))
+		}
+		for k, want := range tt.want {
+			if string(got[k]) != want {
+				t.Errorf("check(%s, %v)[%s] = %s, want %s", tt.meta, tt.partial, k, got[k], want)
+			}
+		}
+	}
+}
+
+func TestSchemaCheckErrors(t *testing.T) {
+	tests := []struct {
+		meta    string
+		partial bool
+		want    string
+	}{
+		{`{"size": "abc"}`, true, "meta.size: must be a number"},
+		{`{"size": true}`, true, "meta.size: must be a number"},
+		{`{"since": "2024-02-30"}`, true, "meta.since: must be a date (YYYY-MM-DD)"},
+		{`{"since": 20240101}`, true, "meta.since: must be a string"},
+		{`{"tier": "bronze"}`, true, "meta.tier: must be one of gold,silver"},
+		{`{"note": 5}`, true, "meta.note: must be a string"},
+
+		// required fields: a merge may omit them but not clear them
+		{`{"tier": null}`, true, "meta.tier is required"},
+		{`{"size": "1"}`, false, "meta.tier is required"},
+		{`{}`, false, "meta.tier is required"},
+	}
+	for _, tt := range tests {
+		var meta map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(tt.meta), &meta); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := testSchema.check(meta, tt.partial)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("check(%s, %v) error = %v, want %q", tt.meta, tt.partial, err, tt.want)
+		}
+	}
+
+	if _, err := testSchema.check(map[string]json.RawMessage{}, true); err != nil {
+		t.Errorf("partial check without required fields: %v", err)
+	}
+}
+
+func TestFieldParse(t *testing.T) {
+	tests := []struct {
+		f    Field
+		in   string
+		want string
+	}{
+		{Field{Type: FieldNumber}, " 12.500 ", `12.5`},
+		{Field{Type: FieldNumber}, "1e3", `1000`},
+		{Field{Type: FieldNumber}, "-0.10", `-0.1`},
+		{Field{Type: FieldDate}, "2025-01-31", `"2025-01-31"`},
+		{Field{Type: FieldEnum, Options: []string{"a b", "c"}}, " a b ", `"a b"`},
+		{Field{Type: FieldText}, "  hola  ", `"hola"`},
+		{Field{Type: FieldText}, "", `""`},
+		{Field{Type: FieldText, Required: true}, `say "hi"`, `"say \"hi\""`},
+	}
+	for _, tt := range tests {
+		got, err := tt.f.parse(tt.in)
+		if err != nil {
+			t.Errorf("%s parse(%q): %v", tt.f.Type, tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s parse(%q) = %s, want %s", tt.f.Type, tt.in, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		f  Field
+		in string
+	}{
+		{Field{Type: FieldNumber}, ""},
+		{Field{Type: FieldNumber}, "12 kg"},
+		{Field{Type: FieldDate}, "31/01/2025"},
+		{Field{Type: FieldDate}, "2025-13-01"},
+		{Field{Type: FieldEnum, Options: []string{"a"}}, "A"},
+		{Field{Type: FieldText, Required: true}, "   "},
+	} {
+		if got, err := tt.f.parse(tt.in); err == nil {
+			t.Errorf("%s parse(%q) = %s, want error", tt.f.Type, tt.in, got)
+		}
+	}
+}
+
+func TestNormTags(t *testing.T) {
+	got, err := normTags([]string{" VIP ", "vip", "Wholesale", "wholesale "})
+	if err != nil {
+		t.Fatalf("normTags: %v", err)
+	}
+	if !slices.Equal(got, []string{"vip", "wholesale"}) {
+		t.Errorf("normTags = %q, want [vip wholesale]", got)
+	}
+
+	if got, err := normTags(nil); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("normTags(nil) = %#v, %v; want an empty slice", got, err)
+	}
+
+	// repeats do not count towards the limit
+	many := []string{}
+	for i := range maxTags {
+		many = append(many, fmt.Sprintf("t%d", i), fmt.Sprintf("T%d", i))
+	}
+	if got, err := normTags(many); err != nil || len(got) != maxTags {
+		t.Errorf("normTags(%d repeated tags) = %d tags, %v; want %d", len(many), len(got), err, maxTags)
+	}
+
+	for name, in := range map[string][]string{
+		"empty":    {"ok", "  "},
+		"too long": {strings.Repeat("x", maxTagLen+1)},
+		"too many": append(many, "one-more"),
+	} {
+		if _, err := normTags(in); err == nil {
+			t.Errorf("normTags(%s) succeeded, want error", name)
+		}
+	}
+}
+
+func TestMetaFilterWithoutParams(t *testing.T) {
+	// Without meta.<key> parameters the schema is not loaded, so no pool is needed.
+	r := httptest.NewRequest("GET", "/clients?q=acme&tag=vip", nil)
+	got, err := metaFilter(r, nil, uuid.Nil)
+	if err != nil || got != nil {
+		t.Errorf("metaFilter = %v, %v; want nil, nil", got, err)
+	}
+
+	r = httptest.NewRequest("GET", "/clients?meta.=x", nil)
+	if _, err := metaFilter(r, nil, uuid.Nil); err == nil {
+		t.Error("metaFilter(meta.=x) succeeded, want error")
+	}
+}
+
+func TestSchemaFilter(t *testing.T) {
+	got, err := testSchema.filter(map[string]string{"size": "10.0", "since": "2024-05-01", "city": "Monterrey"})
+	if err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+	want := map[string]string{"size": `10`, "since": `"2024-05-01"`, "city": `"Monterrey"`}
+	for k, w := range want {
+		if string(got[k]) != w {
+			t.Errorf("filter[%s] = %s, want %s", k, got[k], w)
+		}
+	}
+
+	if _, err := testSchema.filter(map[string]string{"size": "big"}); err == nil || err.Error() != "meta.size: must be a number" {
+		t.Errorf("filter(size=big) error = %v", err)
+	}
+}
@@ -1,22 +1,31 @@
 package clients
 
 import (
-	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/plans"
 	"github.com/roblesvargas97/estimago/internal/utils"
 )
 
 func PostClient(pool *pgxpool.Pool) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
 		var in CreateClientIn
 		if err := utils.DecodeJSON(w, r, &in); err != nil {
 
@@ -31,32 +40,60 @@ func PostClient(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
-		var n int
+		meta := map[string]json.RawMessage{}
+		if in.Meta != nil {
+			if err := json.Unmarshal(*in.Meta, &meta); err != nil || meta == nil {
+				utils.WriteErr(w, http.StatusBadRequest, "validation_error", "meta must be an object")
+				return
+			}
+		}
+
+		tags, err := normTags(in.Tags)
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return
+		}
 
-		if err := pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM clients`).Scan(&n); err != nil {
+		schema, err := loadSchema(r.Context(), pool, ownerID)
+		if err != nil {
 			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 			return
 		}
+		meta, err = schema.check(meta, false)
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return
+		}
 
-		if n >= 3 {
-			utils.WriteErr(w, http.StatusForbidden, "limit_reached", "free tier client limit reached")
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 			return
 		}
+		defer tx.Rollback(r.Context())
 
-		var c Client
+		if err := plans.LockOwner(r.Context(), tx, ownerID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
 
-		meta := []byte(`{}`)
+		n, err := plans.CountClients(r.Context(), tx, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
 
-		if in.Meta != nil {
-			meta = *in.Meta
+		if plan := plans.FromRequest(r); !plan.CanAddClients(n, 1) {
+			utils.WriteErr(w, http.StatusForbidden, "limit_reached", plan.Name+" plan client limit reached")
+			return
 		}
 
-		err := pool.QueryRow(r.Context(), `
-		
-		INSERT INTO clients (name, email, phone, meta)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id,name,email,phone,meta,created_at,updated_at
-		`, in.Name, in.Email, in.Phone, meta).Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Meta, &c.CreatedAt, &c.UpdatedAt)
+		var c Client
+
+		err = scanClient(tx.QueryRow(r.Context(), `
+		INSERT INTO clients (owner_id, name, email, phone, meta, tags)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+clientColumns, ownerID, in.Name, in.Email, in.Phone, meta, tags), &c)
 
 		if err != nil && utils.IsUniqueViolationErr(err) {
 			utils.WriteErr(w, http.StatusConflict, "conflict", "client with same (name,email) already exists")
@@ -67,6 +104,11 @@ func PostClient(pool *pgxpool.Pool) http.HandlerFunc {
 			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 			return
 		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
 		utils.WriteJSON(w, http.StatusCreated, c)
 
 	}
@@ -76,10 +118,22 @@ func PostClient(pool *pgxpool.Pool) http.HandlerFunc {
 func ListClients(pool *pgxpool.Pool) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
 
 		q := strings.TrimSpace(r.URL.Query().Get("q"))
 
+		archived, ok := archivedFilter(w, r)
+		if !ok {
+			return
+		}
+
 		page, _ := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("page"), "1"))
+		if page <= 0 {
+			page = 1
+		}
 
 		limit, _ := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("limit"), "20"))
 
@@ -89,32 +143,45 @@ func ListClients(pool *pgxpool.Pool) http.HandlerFunc {
 
 		offset := (page - 1) * limit
 
-		var total int
+		where := ` WHERE owner_id = $1` + archived
+		args := []any{ownerID}
 
-		if q == "" {
+		if q != "" {
+			where += ` AND (name ILIKE '%' || $2 || '%' OR email ILIKE '%' || $2 || '%')`
+			args = append(args, q)
+		}
 
-			if err := pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM clients`).Scan(&total); err != nil {
-				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		// tag= (repeatable) requires every tag; meta.<key>= matches a custom
+		// field or meta value. Both are containment tests served by GIN indexes.
+		if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+			tags, err := normTags(tags)
+			if err != nil {
+				utils.WriteErr(w, http.StatusBadRequest, "validation_error", err.Error())
 				return
-			} else {
-				if err := pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM clients WHERE name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'`, q).Scan(&total); err != nil {
-					utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
-					return
-				}
 			}
+			args = append(args, tags)
+			where += ` AND tags @> $` + strconv.Itoa(len(args)) + `::text[]`
+		}
 
+		meta, err := metaFilter(r, pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		if len(meta) > 0 {
+			args = append(args, meta)
+			where += ` AND meta @> $` + strconv.Itoa(len(args)) + `::jsonb`
 		}
 
-		sql := `SELECT id, name, email, phone, meta, created_at, updated_at
-		FROM clients
-		`
-		args := []any{}
+		var total int
 
-		if q != "" {
-			sql += ` WHERE name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%' `
-			args = append(args, q)
+		if err := pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM clients`+where, args...).Scan(&total); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
 		}
 
+		sql := `SELECT ` + clientColumns + ` FROM clients` + where
+
 		sql += ` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
 		args = append(args, limit, offset)
 
@@ -130,12 +197,18 @@ func ListClients(pool *pgxpool.Pool) http.HandlerFunc {
 
 		for rows.Next() {
 			var c Client
-			if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Meta, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			if err := scanClient(rows, &c); err != nil {
 				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 				return
 			}
 			outs = append(outs, c)
 		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
 		w.Header().Set("X-Total-Count", strconv.Itoa(total))
 		utils.WriteJSON(w, http.StatusOK, outs)
 
@@ -146,6 +219,10 @@ func ListClients(pool *pgxpool.Pool) http.HandlerFunc {
 func GetClient(pool *pgxpool.Pool) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
 
 		idStr := strings.TrimSpace(chi.URLParam(r, "id"))
 
@@ -155,17 +232,188 @@ func GetClient(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
+		c, err := GetByID(r.Context(), pool, ownerID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			if writeMoved(w, r, pool, ownerID, id) {
+				return
+			}
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+			return
+		}
+
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, c)
+
+	}
+
+}
+
+// PatchClient partially updates a client.
+func PatchClient(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var in UpdateClientIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		sets := []string{}
+		args := []any{id, ownerID}
+		idx := 3
+
+		if in.Name != nil {
+			name := strings.TrimSpace(*in.Name)
+			if name == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("name=$%d", idx))
+			args = append(args, name)
+			idx++
+		}
+
+		nullable, ok := nullableSets(w, []nullableField{{"email", in.Email}, {"phone", in.Phone}}, &args, &idx)
+		if !ok {
+			return
+		}
+		sets = append(sets, nullable...)
+
+		if in.Meta != nil {
+			var meta map[string]json.RawMessage
+			if err := json.Unmarshal(*in.Meta, &meta); err != nil || meta == nil {
+				utils.WriteErr(w, http.StatusBadRequest, "validation_error", "meta must be an object")
+				return
+			}
+
+			mode := strings.ToLower(strings.TrimSpace(in.MetaMode))
+			if mode != "" && mode != "merge" && mode != "replace" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "meta_mode must be one of merge,replace")
+				return
+			}
+
+			schema, err := loadSchema(r.Context(), pool, ownerID)
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			meta, err = schema.check(meta, mode != "replace")
+			if err != nil {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+				return
+			}
+
+			switch mode {
+			case "replace":
+				sets = append(sets, fmt.Sprintf("meta=$%d", idx))
+				args = append(args, meta)
+				idx++
+			default:
+				set, drop := map[string]json.RawMessage{}, []string{}
+				for k, v := range meta {
+					if string(v) == "null" {
+						drop = append(drop, k)
+						continue
+					}
+					set[k] = v
+				}
+				sets = append(sets, fmt.Sprintf("meta=(meta || $%d::jsonb) - $%d::text[]", idx, idx+1))
+				args = append(args, set, drop)
+				idx += 2
+			}
+		} else if in.MetaMode != "" {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "meta_mode requires meta")
+			return
+		}
+
+		if in.Tags != nil {
+			tags, err := normTags(*in.Tags)
+			if err != nil {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+				return
+			}
+			sets = append(sets, fmt.Sprintf("tags=$%d", idx))
+			args = append(args, tags)
+			idx++
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
 		var c Client
+		err = scanClient(pool.QueryRow(r.Context(), `
+		UPDATE clients SET `+strings.Join(sets, ", ")+`, updated_at=now()
+		WHERE id=$1 AND owner_id=$2
+		RETURNING `+clientColumns, args...), &c)
 
-		err = pool.QueryRow(r.Context(), `SELECT id,name,email,phone,meta,created_at,updated_at FROM clients WHERE id=$1`, id).Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Meta, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "client with same (name,email) already exists")
+			return
+		}
 
-		if errors.Is(err, context.DeadlineExceeded) || err != nil {
-			utils.WriteErr(w, http.StatusInternalServerError, "not_found", "client not found")
+		if err != nil {
+			writeClientErr(w, err)
 			return
 		}
 
+		utils.WriteJSON(w, http.StatusOK, c)
+
+	}
+
+}
+
+// ArchiveClient hides a client from ListClients. Its quotes are unaffected.
+func ArchiveClient(pool *pgxpool.Pool) http.HandlerFunc {
+	return setArchived(pool, true)
+}
+
+// UnarchiveClient lists an archived client again.
+func UnarchiveClient(pool *pgxpool.Pool) http.HandlerFunc {
+	return setArchived(pool, false)
+}
+
+func setArchived(pool *pgxpool.Pool, archived bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
 		if err != nil {
-			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		// Archiving twice keeps the original archived_at.
+		var c Client
+		err = scanClient(pool.QueryRow(r.Context(), `
+		UPDATE clients
+		SET archived_at = CASE WHEN $3 THEN COALESCE(archived_at, now()) END, updated_at=now()
+		WHERE id=$1 AND owner_id=$2
+		RETURNING `+clientColumns, id, ownerID, archived), &c)
+
+		if err != nil {
+			writeClientErr(w, err)
 			return
 		}
 
@@ -174,3 +422,116 @@ func GetClient(pool *pgxpool.Pool) http.HandlerFunc {
 	}
 
 }
+
+// DeleteClient permanently removes a client. It is refused while any of the
+// client's quotes has been sent; drafts are kept without a client.
+func DeleteClient(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		// Locking the client keeps quotes from being assigned to it meanwhile.
+		var exists bool
+		err = tx.QueryRow(r.Context(), `SELECT true FROM clients WHERE id=$1 AND owner_id=$2 FOR UPDATE`, id, ownerID).Scan(&exists)
+		if err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		// Locking its quotes keeps a draft from being sent meanwhile and then
+		// losing its client; a send that got there first is seen here.
+		rows, err := tx.Query(r.Context(), `SELECT status FROM quotes WHERE client_id=$1 FOR UPDATE`, id)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		sent := 0
+		for rows.Next() {
+			var status string
+			if err := rows.Scan(&status); err != nil {
+				rows.Close()
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			if status != "draft" {
+				sent++
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if sent > 0 {
+			utils.WriteErr(w, http.StatusConflict, "client_in_use", fmt.Sprintf("client has %d sent quote(s); archive it instead", sent))
+			return
+		}
+
+		if _, err := tx.Exec(r.Context(), `DELETE FROM clients WHERE id=$1 AND owner_id=$2`, id, ownerID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	}
+
+}
+
+func writeClientErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+// nullableString decodes a JSON string or null, trimming whitespace.
+func nullableString(raw json.RawMessage) (*string, error) {
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+	return &s, nil
+}
+
+// archivedFilter turns the archived query parameter into a WHERE condition.
+// Archived clients are hidden unless asked for: archived=true selects only
+// them, archived=all every client.
+func archivedFilter(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("archived"))) {
+	case "", "false":
+		return ` AND archived_at IS NULL`, true
+	case "true":
+		return ` AND archived_at IS NOT NULL`, true
+	case "all":
+		return ``, true
+	}
+	utils.WriteErr(w, http.StatusBadRequest, "validation_error", "archived must be one of true,false,all")
+	return "", false
+}
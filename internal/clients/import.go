@@ -0,0 +1,506 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/plans"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+const (
+	maxImportBytes = 2 << 20
+	maxImportRows  = 5000
+)
+
+// importRow is a client read from an import file.
+type importRow struct {
+	row   int
+	name  string
+	email *string
+	phone *string
+	meta  map[string]string
+	tags  []string
+
+	// values is meta checked against the account's custom fields.
+	values map[string]json.RawMessage
+}
+
+// ImportClients - Creates clients in bulk from a CSV or vCard file
+// Purpose: Onboards an account's existing customer list in one request
+// Advantages:
+//   - multipart/form-data: "file", plus optional "format" (csv or vcf, else taken from the
+//     file name) and "mapping" (ImportMapping as JSON, CSV only)
+//   - ?dry_run=true validates everything and reports what would happen without writing
+//   - Per-row errors and (name,email) conflicts are reported; the other rows are imported
+//     in one transaction
+//
+// Weaknesses:
+//   - Conflicting rows are skipped, never merged into the existing client
+//   - vCard support covers the common properties (FN, N, ORG, EMAIL, TEL, CATEGORIES)
+func ImportClients(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		plan := plans.FromRequest(r)
+		if !plan.Has(plans.FeatureClientImport) {
+			utils.WriteErr(w, http.StatusForbidden, "feature_unavailable", "client import is not included in your plan")
+			return
+		}
+
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+		if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.WriteErr(w, http.StatusRequestEntityTooLarge, "too_large", "import file must be at most 2MB")
+				return
+			}
+			utils.WriteErr(w, http.StatusBadRequest, "bad_request", "body must be multipart/form-data")
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "file is required")
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+		if format == "" {
+			switch strings.ToLower(filepath.Ext(header.Filename)) {
+			case ".vcf", ".vcard":
+				format = "vcf"
+			default:
+				format = "csv"
+			}
+		}
+
+		var rows []importRow
+		switch format {
+		case "csv":
+			var mapping *ImportMapping
+			if raw := r.FormValue("mapping"); raw != "" {
+				mapping = &ImportMapping{}
+				if err := utils.DecodeJSONBytes([]byte(raw), mapping); err != nil {
+					utils.WriteErr(w, http.StatusBadRequest, "bad_json", "mapping: "+err.Error())
+					return
+				}
+			}
+			rows, err = parseCSV(data, mapping)
+		case "vcf":
+			rows, err = parseVCards(data)
+		default:
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "format must be one of csv,vcf")
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return
+		}
+		if len(rows) > maxImportRows {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", fmt.Sprintf("at most %d clients can be imported at once", maxImportRows))
+			return
+		}
+
+		res, valid, err := checkImport(r, pool, ownerID, rows)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		res.DryRun = dryRun
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		if err := plans.LockOwner(r.Context(), tx, ownerID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		n, err := plans.CountClients(r.Context(), tx, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if !plan.CanAddClients(n, len(valid)) {
+			utils.WriteErr(w, http.StatusForbidden, "limit_reached", fmt.Sprintf("%s plan client limit reached: cannot add %d clients", plan.Name, len(valid)))
+			return
+		}
+
+		if dryRun || len(valid) == 0 {
+			utils.WriteJSON(w, http.StatusOK, res)
+			return
+		}
+
+		// Rows taken meanwhile by a concurrent request are skipped.
+		for _, row := range valid {
+			tag, err := tx.Exec(r.Context(), `
+			INSERT INTO clients (owner_id, name, email, phone, meta, tags)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (owner_id, name, email) DO NOTHING`, ownerID, row.name, row.email, row.phone, row.values, row.tags)
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			res.Imported += int(tag.RowsAffected())
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, res)
+
+	}
+
+}
+
+// checkImport validates rows and finds (name, email) conflicts, returning
+// the rows that can be imported.
+func checkImport(r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID, rows []importRow) (ImportResult, []importRow, error) {
+	res := ImportResult{Rows: len(rows), Errors: []ImportRowError{}, Conflicts: []ImportConflict{}}
+
+	schema, err := loadSchema(r.Context(), pool, ownerID)
+	if err != nil {
+		return res, nil, err
+	}
+
+	checked := []importRow{}
+	names, emails := []string{}, []string{}
+	for _, row := range rows {
+		switch {
+		case row.name == "":
+			res.Errors = append(res.Errors, ImportRowError{Row: row.row, Error: "name is required"})
+			continue
+		case row.email != nil && (!strings.Contains(*row.email, "@") || strings.ContainsAny(*row.email, " \t")):
+			res.Errors = append(res.Errors, ImportRowError{Row: row.row, Error: fmt.Sprintf("email %q is not valid", *row.email)})
+			continue
+		}
+
+		meta := make(map[string]json.RawMessage, len(row.meta))
+		for k, v := range row.meta {
+			meta[k], _ = json.Marshal(v)
+		}
+		if row.values, err = schema.check(meta, false); err != nil {
+			res.Errors = append(res.Errors, ImportRowError{Row: row.row, Error: err.Error()})
+			continue
+		}
+		if row.tags, err = normTags(row.tags); err != nil {
+			res.Errors = append(res.Errors, ImportRowError{Row: row.row, Error: err.Error()})
+			continue
+		}
+
+		checked = append(checked, row)
+		// Clients without an email never conflict: NULLs are distinct in
+		// the unique index.
+		if row.email != nil {
+			names, emails = append(names, row.name), append(emails, *row.email)
+		}
+	}
+
+	existing := map[[2]string]ImportConflict{}
+	if len(names) > 0 {
+		dbRows, err := pool.Query(r.Context(), `
+			SELECT id, name, email FROM clients
+			WHERE owner_id=$1 AND (name, email) IN (SELECT * FROM unnest($2::text[], $3::text[]))`,
+			ownerID, names, emails)
+		if err != nil {
+			return res, nil, err
+		}
+		defer dbRows.Close()
+		for dbRows.Next() {
+			var c ImportConflict
+			var id uuid.UUID
+			if err := dbRows.Scan(&id, &c.Name, &c.Email); err != nil {
+				return res, nil, err
+			}
+			c.ClientID = &id
+			existing[[2]string{c.Name, c.Email}] = c
+		}
+		if err := dbRows.Err(); err != nil {
+			return res, nil, err
+		}
+	}
+
+	valid := []importRow{}
+	seen := map[[2]string]int{}
+	for _, row := range checked {
+		if row.email == nil {
+			valid = append(valid, row)
+			continue
+		}
+		key := [2]string{row.name, *row.email}
+		if c, ok := existing[key]; ok {
+			c.Row = row.row
+			res.Conflicts = append(res.Conflicts, c)
+			continue
+		}
+		if first, ok := seen[key]; ok {
+			res.Conflicts = append(res.Conflicts, ImportConflict{Row: row.row, Name: row.name, Email: *row.email, SameAs: &first})
+			continue
+		}
+		seen[key] = row.row
+		valid = append(valid, row)
+	}
+
+	res.Valid = len(valid)
+	return res, valid, nil
+}
+
+// parseCSV reads clients from a CSV file with a header row.
+func parseCSV(data []byte, m *ImportMapping) ([]importRow, error) {
+	rd := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	rd.FieldsPerRecord = -1
+	rd.TrimLeadingSpace = true
+
+	header, err := rd.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	// Mapped columns must exist; the default ones are optional but name.
+	required := m != nil
+	if m == nil {
+		m = &ImportMapping{Name: "name", Email: "email", Phone: "phone", Tags: "tags"}
+	}
+	column := func(field, header string, must bool) (int, error) {
+		if header == "" && !must {
+			return -1, nil
+		}
+		i, ok := cols[strings.ToLower(strings.TrimSpace(header))]
+		if !ok && must && required {
+			return -1, fmt.Errorf("mapping.%s: column %q not found", field, header)
+		}
+		if !ok && must {
+			return -1, fmt.Errorf("column %q not found", header)
+		}
+		if !ok {
+			return -1, nil
+		}
+		return i, nil
+	}
+
+	nameCol, err := column("name", m.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	emailCol, err := column("email", m.Email, required && m.Email != "")
+	if err != nil {
+		return nil, err
+	}
+	phoneCol, err := column("phone", m.Phone, required && m.Phone != "")
+	if err != nil {
+		return nil, err
+	}
+	tagsCol, err := column("tags", m.Tags, required && m.Tags != "")
+	if err != nil {
+		return nil, err
+	}
+	metaCols := map[string]int{}
+	for key, h := range m.Meta {
+		i, err := column("meta."+key, h, true)
+		if err != nil {
+			return nil, err
+		}
+		metaCols[key] = i
+	}
+
+	rows := []importRow{}
+	for {
+		rec, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := rd.FieldPos(0)
+
+		get := func(i int) string {
+			if i < 0 || i >= len(rec) {
+				return ""
+			}
+			v := rec[i]
+			if len(v) > 1 && v[0] == '\'' && strings.ContainsRune(formulaStart, rune(v[1])) {
+				v = v[1:] // escaped by csvCell
+			}
+			return strings.TrimSpace(v)
+		}
+
+		row := importRow{row: line, name: get(nameCol), email: optional(get(emailCol)), phone: optional(get(phoneCol)),
+			tags: splitTags(get(tagsCol))}
+		for key, i := range metaCols {
+			if v := get(i); v != "" {
+				if row.meta == nil {
+					row.meta = map[string]string{}
+				}
+				row.meta[key] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseVCards reads clients from vCard 2.1, 3.0 or 4.0 cards. The name is
+// FN, else ORG, else N; ORG alongside FN is kept as meta.company and
+// CATEGORIES become tags.
+func parseVCards(data []byte) ([]importRow, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	// Unfold continuation lines, which start with a space or tab.
+	lines := []string{}
+	for _, l := range strings.Split(text, "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	rows := []importRow{}
+	var (
+		card         *importRow
+		fn, org, nme string
+	)
+	for _, l := range lines {
+		key, value, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(key, ";")
+		prop := strings.ToUpper(params[0])
+		if i := strings.LastIndex(prop, "."); i >= 0 {
+			prop = prop[i+1:] // item1.EMAIL
+		}
+
+		switch {
+		case prop == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			card = &importRow{row: len(rows) + 1}
+			fn, org, nme = "", "", ""
+		case card == nil:
+			continue
+		case prop == "END" && strings.EqualFold(value, "VCARD"):
+			card.name = fn
+			switch {
+			case card.name == "" && org != "":
+				card.name = org
+			case card.name == "":
+				card.name = nme
+			case org != "" && org != fn:
+				card.meta = map[string]string{"company": org}
+			}
+			rows = append(rows, *card)
+			card = nil
+		case prop == "FN":
+			fn = strings.TrimSpace(vcardUnescape(value))
+		case prop == "ORG":
+			org = strings.TrimSpace(vcardUnescape(strings.SplitN(value, ";", 2)[0]))
+		case prop == "N":
+			// Family;Given;Additional;Prefix;Suffix
+			parts := strings.Split(value, ";")
+			given := []string{}
+			for _, i := range []int{3, 1, 2, 0, 4} {
+				if i < len(parts) && strings.TrimSpace(parts[i]) != "" {
+					given = append(given, strings.TrimSpace(vcardUnescape(parts[i])))
+				}
+			}
+			nme = strings.Join(given, " ")
+		case prop == "EMAIL" && card.email == nil:
+			card.email = optional(vcardUnescape(value))
+		case prop == "TEL" && card.phone == nil:
+			card.phone = optional(strings.TrimPrefix(vcardUnescape(value), "tel:"))
+		case prop == "CATEGORIES":
+			for _, t := range splitUnescaped(value, ',') {
+				if t = strings.TrimSpace(vcardUnescape(t)); t != "" {
+					card.tags = append(card.tags, t)
+				}
+			}
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("no vCards found")
+	}
+	return rows, nil
+}
+
+var vcardUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func vcardUnescape(s string) string {
+	return vcardUnescaper.Replace(s)
+}
+
+// splitUnescaped splits a vCard value on sep, except where it is escaped
+// with a backslash. The parts are still escaped.
+func splitUnescaped(s string, sep byte) []string {
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitTags splits a list of tags separated by ";" or ",".
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// optional trims s, returning nil when it is empty.
+func optional(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
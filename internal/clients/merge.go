@@ -0,0 +1,167 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// MergeClient merges the client from_id into the client in the URL, in one
+// transaction: quotes, contacts and addresses move to the survivor, which
+// also gains the email, phone, meta keys and tags it lacks. The merged client is
+// deleted and its id redirects to the survivor.
+func MergeClient(pool *pgxpool.Pool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := parseID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var in MergeClientIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		if in.FromID == uuid.Nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "from_id is required")
+			return
+		}
+		if in.FromID == id {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "a client cannot be merged into itself")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		res, err := merge(r.Context(), tx, ownerID, id, in.FromID)
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "the merged email would duplicate another client with the same (name,email)")
+			return
+		}
+		if err != nil {
+			writeClientErr(w, err)
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, res)
+
+	}
+
+}
+
+func merge(ctx context.Context, tx pgx.Tx, ownerID, toID, fromID uuid.UUID) (MergeResult, error) {
+	// Lock both in a fixed order so concurrent merges cannot deadlock.
+	var locked int
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM clients WHERE id = ANY($1) AND owner_id=$2 ORDER BY id FOR UPDATE
+		) c`, []uuid.UUID{toID, fromID}, ownerID).Scan(&locked)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if locked != 2 {
+		return MergeResult{}, pgx.ErrNoRows
+	}
+
+	var from Client
+	if err := scanClient(tx.QueryRow(ctx, `SELECT `+clientColumns+` FROM clients WHERE id=$1`, fromID), &from); err != nil {
+		return MergeResult{}, err
+	}
+
+	var res MergeResult
+	tag, err := tx.Exec(ctx, `UPDATE quotes SET client_id=$1, updated_at=now() WHERE client_id=$2 AND owner_id=$3`, toID, fromID, ownerID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	res.MovedQuotes = tag.RowsAffected()
+
+	// The survivor keeps its primary contact and address; the moved ones
+	// only become primary if it had none.
+	for _, m := range []struct {
+		table string
+		moved *int64
+	}{{"client_contacts", &res.MovedContacts}, {"client_addresses", &res.MovedAddresses}} {
+		tag, err := tx.Exec(ctx, `
+			UPDATE `+m.table+`
+			SET client_id=$1, updated_at=now(),
+			    is_primary = is_primary AND NOT EXISTS (SELECT 1 FROM `+m.table+` WHERE client_id=$1 AND is_primary)
+			WHERE client_id=$2`, toID, fromID)
+		if err != nil {
+			return MergeResult{}, err
+		}
+		*m.moved = tag.RowsAffected()
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE client_redirects SET to_id=$1 WHERE to_id=$2`, toID, fromID); err != nil {
+		return MergeResult{}, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM clients WHERE id=$1`, fromID); err != nil {
+		return MergeResult{}, err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO client_redirects (from_id, to_id, owner_id) VALUES ($1, $2, $3)`, fromID, toID, ownerID); err != nil {
+		return MergeResult{}, err
+	}
+
+	err = scanClient(tx.QueryRow(ctx, `
+		UPDATE clients SET
+			email = COALESCE(email, $2),
+			phone = COALESCE(phone, $3),
+			meta = $4::jsonb || meta,
+			tags = tags || ARRAY(SELECT unnest($5::text[]) EXCEPT SELECT unnest(tags)),
+			updated_at = now()
+		WHERE id=$1
+		RETURNING `+clientColumns, toID, from.Email, from.Phone, from.Meta, from.Tags), &res.Client)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	return res, nil
+}
+
+// redirectOf returns the client a merged client id now points to. It returns
+// pgx.ErrNoRows when id was never merged.
+func redirectOf(ctx context.Context, pool *pgxpool.Pool, ownerID, id uuid.UUID) (uuid.UUID, error) {
+	var to uuid.UUID
+	err := pool.QueryRow(ctx, `SELECT to_id FROM client_redirects WHERE from_id=$1 AND owner_id=$2`, id, ownerID).Scan(&to)
+	return to, err
+}
+
+// writeMoved answers a lookup of a merged client with a redirect to the
+// client it was merged into.
+func writeMoved(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID, id uuid.UUID) bool {
+	to, err := redirectOf(r.Context(), pool, ownerID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return true
+	}
+	w.Header().Set("Location", "/api/v1/clients/"+to.String())
+	utils.WriteErr(w, http.StatusMovedPermanently, "client_merged", "client was merged into "+to.String())
+	return true
+}
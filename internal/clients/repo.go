@@ -0,0 +1,100 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// clientColumns lists the columns read into a Client, in scanClient order.
+const clientColumns = `id, name, email, phone, meta, archived_at, tags, created_at, updated_at`
+
+// scanClient reads a row selected with clientColumns.
+func scanClient(row pgx.Row, c *Client) error {
+	return row.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Meta, &c.ArchivedAt, &c.Tags, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// GetByID fetches a client owned by ownerID. It returns pgx.ErrNoRows when the
+// client does not exist or belongs to another user.
+func GetByID(ctx context.Context, pool *pgxpool.Pool, ownerID, id uuid.UUID) (Client, error) {
+	var c Client
+	err := scanClient(pool.QueryRow(ctx, `SELECT `+clientColumns+` FROM clients WHERE id=$1 AND owner_id=$2`, id, ownerID), &c)
+	return c, err
+}
+
+// Exists reports whether ownerID owns a client with the given id.
+func Exists(ctx context.Context, pool *pgxpool.Pool, ownerID, id uuid.UUID) (bool, error) {
+	var ok bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM clients WHERE id=$1 AND owner_id=$2)`, id, ownerID).Scan(&ok)
+	return ok, err
+}
+
+const contactColumns = `id, client_id, name, role, email, phone, is_primary, created_at, updated_at`
+
+func scanContact(row pgx.Row, c *Contact) error {
+	return row.Scan(&c.ID, &c.ClientID, &c.Name, &c.Role, &c.Email, &c.Phone, &c.IsPrimary, &c.CreatedAt, &c.UpdatedAt)
+}
+
+const addressColumns = `id, client_id, label, line1, line2, city, state, postal_code, country, is_primary, created_at, updated_at`
+
+func scanAddress(row pgx.Row, a *Address) error {
+	return row.Scan(&a.ID, &a.ClientID, &a.Label, &a.Line1, &a.Line2, &a.City, &a.State, &a.PostalCode, &a.Country,
+		&a.IsPrimary, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// GetContact fetches a contact of a client owned by ownerID. It returns
+// pgx.ErrNoRows when the contact does not exist or belongs to another client.
+func GetContact(ctx context.Context, pool *pgxpool.Pool, ownerID, clientID, id uuid.UUID) (Contact, error) {
+	var c Contact
+	err := scanContact(pool.QueryRow(ctx, `
+		SELECT `+contactColumns+` FROM client_contacts
+		WHERE id=$1 AND client_id=$2 AND EXISTS (SELECT 1 FROM clients WHERE id=$2 AND owner_id=$3)
+	`, id, clientID, ownerID), &c)
+	return c, err
+}
+
+// GetAddress fetches an address of a client owned by ownerID. It returns
+// pgx.ErrNoRows when the address does not exist or belongs to another client.
+func GetAddress(ctx context.Context, pool *pgxpool.Pool, ownerID, clientID, id uuid.UUID) (Address, error) {
+	var a Address
+	err := scanAddress(pool.QueryRow(ctx, `
+		SELECT `+addressColumns+` FROM client_addresses
+		WHERE id=$1 AND client_id=$2 AND EXISTS (SELECT 1 FROM clients WHERE id=$2 AND owner_id=$3)
+	`, id, clientID, ownerID), &a)
+	return a, err
+}
+
+// lockClient locks a client owned by ownerID for the rest of tx, which
+// serializes changes to the primary flags of its contacts and addresses.
+// It returns pgx.ErrNoRows when the client does not exist.
+func lockClient(ctx context.Context, tx pgx.Tx, ownerID, id uuid.UUID) error {
+	var found bool
+	return tx.QueryRow(ctx, `SELECT true FROM clients WHERE id=$1 AND owner_id=$2 FOR UPDATE`, id, ownerID).Scan(&found)
+}
+
+// clearPrimary unsets the primary row of a client in table (client_contacts
+// or client_addresses), so another row can take the flag.
+func clearPrimary(ctx context.Context, tx pgx.Tx, table string, clientID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `UPDATE `+table+` SET is_primary=false, updated_at=now() WHERE client_id=$1 AND is_primary`, clientID)
+	return err
+}
+
+// hasRows reports whether the client has any row in table.
+func hasRows(ctx context.Context, tx pgx.Tx, table string, clientID uuid.UUID) (bool, error) {
+	var ok bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE client_id=$1)`, clientID).Scan(&ok)
+	return ok, err
+}
+
+// promoteOldest makes the client's oldest row in table primary when none
+// is, after the primary one was deleted.
+func promoteOldest(ctx context.Context, tx pgx.Tx, table string, clientID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE `+table+` SET is_primary=true, updated_at=now()
+		WHERE id = (SELECT id FROM `+table+` WHERE client_id=$1 ORDER BY created_at, id LIMIT 1)
+		  AND NOT EXISTS (SELECT 1 FROM `+table+` WHERE client_id=$1 AND is_primary)
+	`, clientID)
+	return err
+}
@@ -15,6 +15,9 @@ type Client struct {
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
 	Meta      json.RawMessage `json:"meta"`
+	// ArchivedAt is set while the client is archived and hidden from the list.
+	ArchivedAt *time.Time `json:"archived_at"`
+	Tags       []string   `json:"tags"`
 }
 
 type CreateClientIn struct {
@@ -22,4 +25,185 @@ type CreateClientIn struct {
 	Email *string          `json:"email,omitempty"`
 	Phone *string          `json:"phone,omitempty"`
 	Meta  *json.RawMessage `json:"meta,omitempty"`
+	Tags  []string         `json:"tags,omitempty"`
+}
+
+// UpdateClientIn is the body of PATCH /clients/{id}; absent fields are left
+// unchanged and email or phone can be cleared with null. Meta is merged
+// into the stored meta, a null value removing its key, unless MetaMode is
+// "replace".
+type UpdateClientIn struct {
+	Name     *string          `json:"name"`
+	Email    *json.RawMessage `json:"email"`
+	Phone    *json.RawMessage `json:"phone"`
+	Meta     *json.RawMessage `json:"meta"`
+	MetaMode string           `json:"meta_mode"` // merge (default) or replace
+	Tags     *[]string        `json:"tags"`      // replaces the tags
+}
+
+// ContactFields are the details of a person at a client, also snapshotted
+// into quotes addressed to them.
+type ContactFields struct {
+	Name  string  `json:"name"`
+	Role  *string `json:"role"` // billing, site, ...
+	Email *string `json:"email"`
+	Phone *string `json:"phone"`
+}
+
+type Contact struct {
+	ID       uuid.UUID `json:"id"`
+	ClientID uuid.UUID `json:"client_id"`
+	ContactFields
+	IsPrimary bool      `json:"is_primary"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateContactIn struct {
+	ContactFields
+	IsPrimary bool `json:"is_primary"`
+}
+
+// UpdateContactIn is the body of PATCH; role, email and phone can be
+// cleared with null.
+type UpdateContactIn struct {
+	Name      *string          `json:"name"`
+	Role      *json.RawMessage `json:"role"`
+	Email     *json.RawMessage `json:"email"`
+	Phone     *json.RawMessage `json:"phone"`
+	IsPrimary *bool            `json:"is_primary"`
+}
+
+// AddressFields are a postal address of a client, such as a job site, also
+// snapshotted into quotes for work there.
+type AddressFields struct {
+	Label      *string `json:"label"` // "Warehouse", "Main office"
+	Line1      string  `json:"line1"`
+	Line2      *string `json:"line2"`
+	City       *string `json:"city"`
+	State      *string `json:"state"`
+	PostalCode *string `json:"postal_code"`
+	Country    *string `json:"country"`
+}
+
+type Address struct {
+	ID       uuid.UUID `json:"id"`
+	ClientID uuid.UUID `json:"client_id"`
+	AddressFields
+	IsPrimary bool      `json:"is_primary"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateAddressIn struct {
+	AddressFields
+	IsPrimary bool `json:"is_primary"`
+}
+
+// UpdateAddressIn is the body of PATCH; every field but line1 can be
+// cleared with null.
+type UpdateAddressIn struct {
+	Label      *json.RawMessage `json:"label"`
+	Line1      *string          `json:"line1"`
+	Line2      *json.RawMessage `json:"line2"`
+	City       *json.RawMessage `json:"city"`
+	State      *json.RawMessage `json:"state"`
+	PostalCode *json.RawMessage `json:"postal_code"`
+	Country    *json.RawMessage `json:"country"`
+	IsPrimary  *bool            `json:"is_primary"`
+}
+
+// DuplicateGroup is a set of clients that look like the same customer.
+// Reasons lists what they share: name, email or phone, once normalized.
+type DuplicateGroup struct {
+	Reasons []string `json:"reasons"`
+	Clients []Client `json:"clients"`
+}
+
+// MergeClientIn is the body of POST /clients/{id}/merge: FromID is merged
+// into the client in the URL and removed.
+type MergeClientIn struct {
+	FromID uuid.UUID `json:"from_id"`
+}
+
+type MergeResult struct {
+	Client         Client `json:"client"`
+	MovedQuotes    int64  `json:"moved_quotes"`
+	MovedContacts  int64  `json:"moved_contacts"`
+	MovedAddresses int64  `json:"moved_addresses"`
+}
+
+// ImportMapping maps client fields to CSV column headers. Meta maps meta
+// keys to columns. Without a mapping, columns named name, email, phone and
+// tags are used. Tags are separated by ";" or ",".
+type ImportMapping struct {
+	Name  string            `json:"name"`
+	Email string            `json:"email"`
+	Phone string            `json:"phone"`
+	Tags  string            `json:"tags"`
+	Meta  map[string]string `json:"meta"`
+}
+
+// ImportRowError is a row that cannot be imported. Row is the line of a
+// CSV file (the header is line 1) or the position of a vCard.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportConflict is a row whose (name, email) is already taken, by an
+// existing client or by an earlier row of the file.
+type ImportConflict struct {
+	Row      int        `json:"row"`
+	Name     string     `json:"name"`
+	Email    string     `json:"email"`
+	ClientID *uuid.UUID `json:"client_id,omitempty"` // existing client
+	SameAs   *int       `json:"same_as,omitempty"`   // earlier row
+}
+
+type ImportResult struct {
+	DryRun    bool             `json:"dry_run"`
+	Rows      int              `json:"rows"`
+	Valid     int              `json:"valid"` // rows without errors or conflicts
+	Imported  int              `json:"imported"`
+	Errors    []ImportRowError `json:"errors"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// Field types of custom client fields.
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldDate   = "date" // YYYY-MM-DD
+	FieldEnum   = "enum"
+)
+
+// Field is a custom client field defined by the account. Its value is
+// stored in the client's meta under Key: a JSON string, or a JSON number
+// for number fields.
+type Field struct {
+	ID        uuid.UUID `json:"id"`
+	Key       string    `json:"key"`
+	Label     string    `json:"label"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options"` // enum values
+	Required  bool      `json:"required"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateFieldIn struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
+}
+
+// UpdateFieldIn is the body of PATCH /client-fields/{id}. The key and type
+// cannot change, as stored values depend on them.
+type UpdateFieldIn struct {
+	Label    *string   `json:"label"`
+	Options  *[]string `json:"options"`
+	Required *bool     `json:"required"`
 }
@@ -14,6 +14,10 @@ type Config struct {
 	AppEnv        string
 	AuthJWTSecret string
 	AuthJWTTTLHrs int
+
+	// QuoteExpirySweepMins is how often sent quotes past their validity date
+	// are moved to expired.
+	QuoteExpirySweepMins int
 }
 
 func Load() Config {
@@ -50,6 +54,11 @@ func Load() Config {
 		}
 	}
 
+	cfg.QuoteExpirySweepMins = 60
+	if v, err := strconv.Atoi(os.Getenv("QUOTE_EXPIRY_SWEEP_MINUTES")); err == nil && v > 0 {
+		cfg.QuoteExpirySweepMins = v
+	}
+
 	if cfg.AuthJWTSecret == "" {
 		log.Fatal("AUTH_JWT_SECRET no configurado")
 	}
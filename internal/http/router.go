@@ -11,9 +11,13 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/accounts"
 	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/catalog"
 	"github.com/roblesvargas97/estimago/internal/clients"
+	"github.com/roblesvargas97/estimago/internal/plans"
 	"github.com/roblesvargas97/estimago/internal/quotes"
+	"github.com/roblesvargas97/estimago/internal/taxes"
 )
 
 func NewRouter(pool *pgxpool.Pool, authCfg auth.Config) *chi.Mux {
@@ -22,7 +26,7 @@ func NewRouter(pool *pgxpool.Pool, authCfg auth.Config) *chi.Mux {
 	r.Use(middleware.RequestID, middleware.RealIP, middleware.Logger, middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 		ExposedHeaders: []string{"*"},
 		MaxAge:         300,
@@ -62,23 +66,95 @@ func NewRouter(pool *pgxpool.Pool, authCfg auth.Config) *chi.Mux {
 		auth.RegisterRoutes(sub, pool, authCfg)
 	})
 
+	r.Route("/p/quotes/{public_id}", func(r chi.Router) {
+		r.Get("/", quotes.PublicGetQuote(pool))
+		r.Post("/accept", quotes.PublicAcceptQuote(pool))
+		r.Post("/reject", quotes.PublicRejectQuote(pool))
+	})
+
 	r.Group(func(priv chi.Router) {
 		priv.Use(auth.JWTMiddleware(authCfg))
 		priv.Get("/api/v1/me", auth.MeHandler(pool))
-	})
-
-	r.Route("/api/v1/clients", func(r chi.Router) {
-		r.Post("/", clients.PostClient(pool))
-		r.Get("/", clients.ListClients(pool))
-		r.Get("/{id}", clients.GetClient(pool))
-	})
-
-	r.Route("/api/v1/quotes", func(r chi.Router) {
-		r.Post("/", quotes.PostQuote(pool))
-		r.Get("/", quotes.ListQuotes(pool))
-		r.Get("/{id}", quotes.GetQuote(pool))
-		r.Patch("/{id}", quotes.PatchQuote(pool))
-		r.Post("/{id}/send", quotes.SendQuote(pool))
+		priv.Get("/api/v1/me/usage", plans.UsageHandler(pool))
+		priv.Get("/api/v1/me/settings", accounts.GetSettings(pool))
+		priv.Patch("/api/v1/me/settings", accounts.PatchSettings(pool))
+		priv.Get("/api/v1/me/settings/logo", accounts.GetLogoHandler(pool))
+		priv.Put("/api/v1/me/settings/logo", accounts.PutLogo(pool))
+		priv.Delete("/api/v1/me/settings/logo", accounts.DeleteLogo(pool))
+
+		priv.Route("/api/v1/clients", func(r chi.Router) {
+			r.Post("/", clients.PostClient(pool))
+			r.Get("/", clients.ListClients(pool))
+			r.Get("/duplicates", clients.ListDuplicates(pool))
+			r.Post("/import", clients.ImportClients(pool))
+			r.Get("/export", clients.ExportClients(pool))
+			r.Get("/{id}", clients.GetClient(pool))
+			r.Patch("/{id}", clients.PatchClient(pool))
+			r.Delete("/{id}", clients.DeleteClient(pool))
+			r.Post("/{id}/archive", clients.ArchiveClient(pool))
+			r.Post("/{id}/unarchive", clients.UnarchiveClient(pool))
+			r.Post("/{id}/merge", clients.MergeClient(pool))
+
+			r.Get("/{id}/contacts", clients.ListContacts(pool))
+			r.Post("/{id}/contacts", clients.PostContact(pool))
+			r.Get("/{id}/contacts/{contact_id}", clients.GetContactHandler(pool))
+			r.Patch("/{id}/contacts/{contact_id}", clients.PatchContact(pool))
+			r.Delete("/{id}/contacts/{contact_id}", clients.DeleteContact(pool))
+
+			r.Get("/{id}/addresses", clients.ListAddresses(pool))
+			r.Post("/{id}/addresses", clients.PostAddress(pool))
+			r.Get("/{id}/addresses/{address_id}", clients.GetAddressHandler(pool))
+			r.Patch("/{id}/addresses/{address_id}", clients.PatchAddress(pool))
+			r.Delete("/{id}/addresses/{address_id}", clients.DeleteAddress(pool))
+		})
+
+		priv.Route("/api/v1/client-fields", func(r chi.Router) {
+			r.Post("/", clients.PostField(pool))
+			r.Get("/", clients.ListFields(pool))
+			r.Get("/{id}", clients.GetField(pool))
+			r.Patch("/{id}", clients.PatchField(pool))
+			r.Delete("/{id}", clients.DeleteField(pool))
+		})
+
+		priv.Route("/api/v1/taxes", func(r chi.Router) {
+			r.Post("/", taxes.PostRate(pool))
+			r.Get("/", taxes.ListRates(pool))
+			r.Get("/{id}", taxes.GetRate(pool))
+			r.Patch("/{id}", taxes.PatchRate(pool))
+			r.Delete("/{id}", taxes.DeleteRate(pool))
+		})
+
+		priv.Route("/api/v1/catalog", func(r chi.Router) {
+			r.Post("/", catalog.PostItem(pool))
+			r.Get("/", catalog.ListItems(pool))
+			r.Get("/{id}", catalog.GetItem(pool))
+			r.Patch("/{id}", catalog.PatchItem(pool))
+			r.Delete("/{id}", catalog.DeleteItem(pool))
+		})
+
+		priv.Route("/api/v1/quote-templates", func(r chi.Router) {
+			r.Post("/", quotes.PostTemplate(pool))
+			r.Get("/", quotes.ListTemplates(pool))
+			r.Get("/{id}", quotes.GetTemplate(pool))
+			r.Patch("/{id}", quotes.PatchTemplate(pool))
+			r.Delete("/{id}", quotes.DeleteTemplate(pool))
+		})
+
+		priv.Route("/api/v1/quotes", func(r chi.Router) {
+			r.Post("/", quotes.PostQuote(pool))
+			r.Post("/preview", quotes.PreviewQuote(pool))
+			r.Get("/", quotes.ListQuotes(pool))
+			r.Get("/{id}", quotes.GetQuote(pool))
+			r.Patch("/{id}", quotes.PatchQuote(pool))
+			r.Post("/{id}/send", quotes.SendQuote(pool))
+			r.Post("/{id}/revise", quotes.ReviseQuote(pool))
+			r.Post("/{id}/duplicate", quotes.DuplicateQuote(pool))
+			r.Get("/{id}/events", quotes.ListStatusEvents(pool))
+			r.Get("/{id}/pdf", quotes.QuotePDF(pool))
+			r.Get("/{id}/revisions", quotes.ListRevisions(pool))
+			r.Get("/{id}/revisions/diff", quotes.DiffRevisions(pool))
+			r.Get("/{id}/revisions/{n}", quotes.GetRevision(pool))
+		})
 	})
 
 	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
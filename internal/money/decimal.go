@@ -0,0 +1,318 @@
+// Package money provides Decimal, an exact base-10 number used for every
+// price, quantity, percentage and total. Values never pass through binary
+// floating point: they are parsed from text, computed with big integers,
+// stored as NUMERIC and serialized as JSON strings.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Decimal is coef × 10^-scale. The zero value is 0. Decimals are immutable:
+// every operation returns a new value, so they are safe to copy and share.
+//
+// The scale is preserved, so "12.50" stays "12.50" through a round trip.
+type Decimal struct {
+	coef  *big.Int // nil means zero
+	scale int32    // digits after the decimal point, >= 0
+}
+
+// maxScale bounds the number of fractional digits accepted from input, so a
+// hostile "1e-1000000" cannot allocate huge numbers.
+const maxScale = 30
+
+// ErrInvalid is returned when text is not a plain decimal number.
+var ErrInvalid = errors.New("money: invalid decimal")
+
+// Zero is the decimal 0.
+var Zero = Decimal{}
+
+// New returns coef × 10^-scale.
+func New(coef int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(coef), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(coef), scale: scale}
+}
+
+// NewFromInt returns i as a decimal with no fractional digits.
+func NewFromInt(i int64) Decimal {
+	return New(i, 0)
+}
+
+// Parse reads a decimal in plain or exponent notation: "12", "-0.50", "1.5e3".
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Decimal{}, ErrInvalid
+	}
+
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, ErrInvalid
+		}
+		mantissa, exp = s[:i], e
+	}
+
+	neg := false
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg, mantissa = true, mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+
+	intPart, frac, _ := strings.Cut(mantissa, ".")
+	digits := intPart + frac
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Decimal{}, ErrInvalid
+	}
+
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, ErrInvalid
+	}
+	if neg {
+		coef.Neg(coef)
+	}
+
+	scale := int64(len(frac)) - exp
+	if scale > maxScale || scale < -maxScale {
+		return Decimal{}, ErrInvalid
+	}
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// MustParse is Parse for constants known to be valid; it panics otherwise.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: MustParse(%q): %v", s, err))
+	}
+	return d
+}
+
+// FromRat rounds r to the given number of fractional digits.
+func FromRat(r *big.Rat, places int32, mode RoundingMode) Decimal {
+	n := new(big.Int).Mul(r.Num(), pow10(places))
+	return Decimal{coef: divRound(n, r.Denom(), mode), scale: places}
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Rat returns d as an exact fraction.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.int(), pow10(d.scale))
+}
+
+// Scale returns the number of fractional digits.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// rescale returns d's coefficient expressed with a larger scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.int()
+	}
+	return new(big.Int).Mul(d.int(), pow10(scale-d.scale))
+}
+
+// Add returns d + e.
+func (d Decimal) Add(e Decimal) Decimal {
+	s := max(d.scale, e.scale)
+	return Decimal{coef: new(big.Int).Add(d.rescale(s), e.rescale(s)), scale: s}
+}
+
+// Sub returns d - e.
+func (d Decimal) Sub(e Decimal) Decimal {
+	s := max(d.scale, e.scale)
+	return Decimal{coef: new(big.Int).Sub(d.rescale(s), e.rescale(s)), scale: s}
+}
+
+// Mul returns d × e exactly.
+func (d Decimal) Mul(e Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), e.int()), scale: d.scale + e.scale}
+}
+
+// Percent returns d × p / 100 exactly, e.g. 200.Percent(16) = 32.
+func (d Decimal) Percent(p Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), p.int()), scale: d.scale + p.scale + 2}
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Sign returns -1, 0 or +1.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero reports whether d == 0.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compares d and e by value, ignoring scale: 1.5 == 1.50.
+func (d Decimal) Cmp(e Decimal) int {
+	s := max(d.scale, e.scale)
+	return d.rescale(s).Cmp(e.rescale(s))
+}
+
+// Equal reports whether d and e have the same value.
+func (d Decimal) Equal(e Decimal) bool {
+	return d.Cmp(e) == 0
+}
+
+// Round rounds half-up (away from zero) to exactly places fractional
+// digits, padding with zeros when d has fewer: 2.5.Round(2) = "2.50".
+func (d Decimal) Round(places int32) Decimal {
+	return d.RoundMode(places, RoundHalfUp)
+}
+
+// RoundMode is Round with an explicit rounding mode.
+func (d Decimal) RoundMode(places int32, mode RoundingMode) Decimal {
+	if places >= d.scale {
+		return Decimal{coef: d.rescale(places), scale: places}
+	}
+	return Decimal{coef: divRound(d.int(), pow10(d.scale-places), mode), scale: places}
+}
+
+// Trim drops trailing fractional zeros: "2.500" -> "2.5", "3.00" -> "3".
+func (d Decimal) Trim() Decimal {
+	coef, scale := new(big.Int).Set(d.int()), d.scale
+	ten, r := big.NewInt(10), new(big.Int)
+	for scale > 0 {
+		q, _ := new(big.Int).QuoRem(coef, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		coef, scale = q, scale-1
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// String formats d in plain notation with exactly d.Scale() fractional digits.
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(d.int()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(s); pad > 0 {
+			s = strings.Repeat("0", pad) + s
+		}
+		s = s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+	}
+	if d.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON encodes d as a JSON string so clients never parse it as a float.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a JSON string or number. Numbers are read from their
+// literal text, never through float64. null leaves d unchanged.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return nil
+	}
+
+	text := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &text); err != nil {
+			return err
+		}
+	}
+
+	v, err := Parse(text)
+	if err != nil {
+		return fmt.Errorf("money: %q is not a decimal number", text)
+	}
+	*d = v
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner so NUMERIC columns scan
+// directly into a Decimal. NULL scans as zero.
+func (d *Decimal) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		*d = Decimal{}
+		return nil
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("money: cannot scan non-finite NUMERIC")
+	}
+
+	coef := new(big.Int)
+	if v.Int != nil {
+		coef.Set(v.Int)
+	}
+	if v.Exp >= 0 {
+		*d = Decimal{coef: coef.Mul(coef, pow10(v.Exp))}
+		return nil
+	}
+	*d = Decimal{coef: coef, scale: -v.Exp}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer so a Decimal can be passed as
+// a query argument for NUMERIC columns.
+func (d Decimal) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: new(big.Int).Set(d.int()), Exp: -d.scale, Valid: true}, nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// divRound returns n / m rounded with mode; m must be positive.
+func divRound(n, m *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, m, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	// q is truncated toward zero; decide whether to step away from zero.
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	half := twice.Cmp(m)
+
+	var away bool
+	switch mode {
+	case RoundUp:
+		away = true
+	case RoundHalfEven:
+		away = half > 0 || (half == 0 && q.Bit(0) == 1)
+	default:
+		away = half >= 0
+	}
+
+	if away {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	return q
+}
@@ -0,0 +1,251 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"12", "12"},
+		{"-0.50", "-0.50"},
+		{"+3.1", "3.1"},
+		{"  7 ", "7"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"1.5e3", "1500"},
+		{"1.5E-2", "0.015"},
+		{"-2e0", "-2"},
+		{"1e-30", "0.000000000000000000000000000001"},
+		{"1e30", "1000000000000000000000000000000"},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"abc",
+		"1.2.3",
+		"--1",
+		"1,5",
+		"1e",
+		"e5",
+		"1e2.5",
+		"NaN",
+		"Inf",
+		"1e-31",
+		"1e31",
+		"0.0000000000000000000000000000001",
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", in, err)
+		}
+	}
+}
+
+func TestRoundMode(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int32
+		mode   RoundingMode
+		want   string
+	}{
+		{"2.345", 2, RoundHalfUp, "2.35"},
+		{"2.345", 2, RoundHalfEven, "2.34"},
+		{"2.355", 2, RoundHalfEven, "2.36"},
+		{"2.345", 2, RoundUp, "2.35"},
+		{"2.341", 2, RoundHalfUp, "2.34"},
+		{"2.341", 2, RoundUp, "2.35"},
+		{"2.340", 2, RoundUp, "2.34"},
+
+		{"-2.345", 2, RoundHalfUp, "-2.35"},
+		{"-2.345", 2, RoundHalfEven, "-2.34"},
+		{"-2.355", 2, RoundHalfEven, "-2.36"},
+		{"-2.341", 2, RoundHalfUp, "-2.34"},
+		{"-2.341", 2, RoundUp, "-2.35"},
+
+		{"0.5", 0, RoundHalfUp, "1"},
+		{"0.5", 0, RoundHalfEven, "0"},
+		{"1.5", 0, RoundHalfEven, "2"},
+		{"-0.5", 0, RoundHalfUp, "-1"},
+		{"-0.5", 0, RoundHalfEven, "0"},
+		{"-1.5", 0, RoundHalfEven, "-2"},
+
+		{"2.5", 2, RoundHalfUp, "2.50"},
+		{"7", 3, RoundHalfEven, "7.000"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.in).RoundMode(tt.places, tt.mode)
+		if got.String() != tt.want {
+			t.Errorf("%s.RoundMode(%d, %s) = %s, want %s", tt.in, tt.places, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRoundDefaultsToHalfUp(t *testing.T) {
+	if got := MustParse("-1.005").Round(2).String(); got != "-1.01" {
+		t.Errorf("Round = %s, want -1.01", got)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2.500", "2.5"},
+		{"3.00", "3"},
+		{"-1.10", "-1.1"},
+		{"0.000", "0"},
+		{"100", "100"},
+		{"0.05", "0.05"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Trim().String(); got != tt.want {
+			t.Errorf("%s.Trim() = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		d    Decimal
+		want string
+	}{
+		{Zero, "0"},
+		{New(1250, 2), "12.50"},
+		{New(5, 3), "0.005"},
+		{New(-5, 3), "-0.005"},
+		{New(12, -2), "1200"},
+		{NewFromInt(-42), "-42"},
+		{MustParse("-0.00"), "0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	type line struct {
+		Price Decimal `json:"price"`
+	}
+
+	for _, in := range []string{"12.50", "-0.005", "0", "1000000000000000000000.01"} {
+		b, err := json.Marshal(line{Price: MustParse(in)})
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", in, err)
+		}
+		if want := `{"price":"` + in + `"}`; string(b) != want {
+			t.Errorf("Marshal(%s) = %s, want %s", in, b, want)
+		}
+
+		var out line
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", b, err)
+		}
+		if out.Price.String() != in {
+			t.Errorf("round trip of %s = %s", in, out.Price)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"12.50"`, "12.50"},
+		{`12.50`, "12.50"},
+		{`0.1`, "0.1"},
+		{`1e2`, "100"},
+		{`" 3 "`, "3"},
+		{`null`, "9.99"},
+	}
+	for _, tt := range tests {
+		d := MustParse("9.99")
+		if err := json.Unmarshal([]byte(tt.in), &d); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"abc"`, `""`, `true`, `[1]`, `{}`} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want error", in, d)
+		}
+	}
+}
+
+func TestNumericRoundTrip(t *testing.T) {
+	for _, in := range []string{"12.50", "-0.005", "0", "1200", "123456789012345678901234567890.123"} {
+		v, err := MustParse(in).NumericValue()
+		if err != nil {
+			t.Fatalf("NumericValue(%s): %v", in, err)
+		}
+
+		var d Decimal
+		if err := d.ScanNumeric(v); err != nil {
+			t.Fatalf("ScanNumeric(%s): %v", in, err)
+		}
+		if d.String() != in {
+			t.Errorf("round trip of %s = %s", in, d)
+		}
+	}
+}
+
+func TestScanNumeric(t *testing.T) {
+	tests := []struct {
+		v    pgtype.Numeric
+		want string
+	}{
+		{pgtype.Numeric{Int: big.NewInt(1250), Exp: -2, Valid: true}, "12.50"},
+		{pgtype.Numeric{Int: big.NewInt(12), Exp: 2, Valid: true}, "1200"},
+		{pgtype.Numeric{Valid: true}, "0"},
+		{pgtype.Numeric{}, "0"},
+	}
+	for _, tt := range tests {
+		d := MustParse("9.99")
+		if err := d.ScanNumeric(tt.v); err != nil {
+			t.Errorf("ScanNumeric(%+v): %v", tt.v, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("ScanNumeric(%+v) = %s, want %s", tt.v, d, tt.want)
+		}
+	}
+
+	for _, v := range []pgtype.Numeric{
+		{NaN: true, Valid: true},
+		{InfinityModifier: pgtype.Infinity, Valid: true},
+		{InfinityModifier: pgtype.NegativeInfinity, Valid: true},
+	} {
+		var d Decimal
+		if err := d.ScanNumeric(v); err == nil {
+			t.Errorf("ScanNumeric(%+v) = %s, want error", v, d)
+		}
+	}
+}
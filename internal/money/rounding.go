@@ -0,0 +1,23 @@
+package money
+
+// RoundingMode selects how amounts are rounded to a currency's minor units.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds ties away from zero: 2.345 -> 2.35. The default.
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds ties to the even digit (banker's rounding):
+	// 2.345 -> 2.34, 2.355 -> 2.36.
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundUp rounds any remainder away from zero: 2.341 -> 2.35.
+	RoundUp RoundingMode = "up"
+)
+
+// Valid reports whether m is a known rounding mode.
+func (m RoundingMode) Valid() bool {
+	switch m {
+	case RoundHalfUp, RoundHalfEven, RoundUp:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+// Package pdf is a minimal PDF 1.4 writer: A4 pages, the standard Helvetica
+// fonts, straight lines, filled rectangles and JPEG images. It exists so
+// documents can be produced in pure Go without external dependencies.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // registers the JPEG decoder for image.DecodeConfig
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document collects pages and images and serializes them with Write.
+type Document struct {
+	pages  []*Page
+	images []*Image
+}
+
+// Page is a single page. Coordinates passed to its methods are measured in
+// points from the top-left corner, y growing downwards.
+type Page struct {
+	content bytes.Buffer
+	images  []*Image
+}
+
+// Image is a JPEG registered in a document.
+type Image struct {
+	data   []byte
+	width  int
+	height int
+	name   string
+	gray   bool
+}
+
+// New creates an empty document.
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a blank page.
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// AddJPEG registers a JPEG image so it can be drawn on any page.
+func (d *Document) AddJPEG(data []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format != "jpeg" {
+		return nil, fmt.Errorf("pdf: unsupported image format %q", format)
+	}
+
+	if cfg.ColorModel == color.CMYKModel {
+		return nil, fmt.Errorf("pdf: CMYK JPEG images are not supported")
+	}
+
+	img := &Image{
+		data:   data,
+		width:  cfg.Width,
+		height: cfg.Height,
+		name:   "Im" + strconv.Itoa(len(d.images)+1),
+		// Gray JPEGs carry a single component; everything else is YCbCr,
+		// which DCTDecode hands back as RGB.
+		gray: cfg.ColorModel == color.GrayModel,
+	}
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// Size returns the pixel dimensions of the image.
+func (img *Image) Size() (int, int) {
+	return img.width, img.height
+}
+
+// Text draws s with its baseline at (x, y).
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, num(size), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// TextRight draws s so that it ends at x.
+func (p *Page) TextRight(x, y, size float64, bold bool, s string) {
+	p.Text(x-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// Line draws a straight line of the given width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// FillRect fills a rectangle whose top-left corner is (x, y) with a gray
+// level between 0 (black) and 1 (white).
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "q %s g %s %s %s %s re f Q\n",
+		num(gray), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Image draws img with its top-left corner at (x, y) scaled to w×h.
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(w), num(h), num(x), num(PageHeight-y-h), img.name)
+}
+
+// Write serializes the document.
+func (d *Document) Write(w io.Writer) error {
+	var buf bytes.Buffer
+	offsets := []int{}
+
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Fixed objects: 1 catalog, 2 page tree, 3-4 fonts, then images, then
+	// one page and one content stream per page.
+	const firstImageObj = 5
+	firstPageObj := firstImageObj + len(d.images)
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObj+2*i)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	imageObj := map[*Image]int{}
+	for i, img := range d.images {
+		imageObj[img] = firstImageObj + i
+		cs := "/DeviceRGB"
+		if img.gray {
+			cs = "/DeviceGray"
+		}
+		stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			img.width, img.height, cs), img.data)
+	}
+
+	for i, p := range d.pages {
+		xobjects := ""
+		seen := map[*Image]bool{}
+		for _, img := range p.images {
+			if seen[img] {
+				continue
+			}
+			seen[img] = true
+			xobjects += fmt.Sprintf(" /%s %d 0 R", img.name, imageObj[img])
+		}
+		resources := "/Font << /F1 3 0 R /F2 4 0 R >>"
+		if xobjects != "" {
+			resources += " /XObject <<" + xobjects + " >>"
+		}
+
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), resources, firstPageObj+2*i+1))
+		stream("", p.content.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
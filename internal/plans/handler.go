@@ -0,0 +1,65 @@
+package plans
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// Counter reports current usage against a limit. A nil Limit means unlimited.
+type Counter struct {
+	Used  int  `json:"used"`
+	Limit *int `json:"limit"`
+}
+
+// Usage is the body of GET /api/v1/me/usage.
+type Usage struct {
+	PlanID          string   `json:"plan_id"`
+	PlanName        string   `json:"plan_name"`
+	Features        []string `json:"features"`
+	Clients         Counter  `json:"clients"`
+	QuotesThisMonth Counter  `json:"quotes_this_month"`
+}
+
+// UsageHandler reports the caller's usage versus the limits of their plan.
+func UsageHandler(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		plan := FromRequest(r)
+
+		nClients, err := CountClients(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		nQuotes, err := CountQuotesThisMonth(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, Usage{
+			PlanID:          plan.ID,
+			PlanName:        plan.Name,
+			Features:        plan.Features,
+			Clients:         counter(nClients, plan.MaxClients),
+			QuotesThisMonth: counter(nQuotes, plan.MaxQuotesPerMonth),
+		})
+	}
+}
+
+func counter(used, limit int) Counter {
+	c := Counter{Used: used}
+	if limit != Unlimited {
+		c.Limit = &limit
+	}
+	return c
+}
@@ -0,0 +1,97 @@
+package plans
+
+import (
+	"net/http"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+)
+
+// Unlimited marks a limit that is never enforced.
+const Unlimited = -1
+
+// Feature flags granted by a plan.
+const (
+	FeatureBranding     = "branding"      // custom logo and footer on documents
+	FeatureTemplates    = "templates"     // reusable quote templates
+	FeatureClientImport = "client_import" // bulk client import
+)
+
+// DefaultPlanID is the plan assigned to new users and to unknown plan IDs.
+const DefaultPlanID = "free"
+
+// Plan defines the limits and features of a subscription tier. MaxUsers is
+// not enforced yet: accounts are single-user, the owner being the only member.
+type Plan struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	MaxClients        int      `json:"max_clients"`
+	MaxQuotesPerMonth int      `json:"max_quotes_per_month"`
+	MaxUsers          int      `json:"max_users"`
+	Features          []string `json:"features"`
+}
+
+var catalog = map[string]Plan{
+	"free": {
+		ID:                "free",
+		Name:              "Free",
+		MaxClients:        3,
+		MaxQuotesPerMonth: 10,
+		MaxUsers:          1,
+		Features:          []string{},
+	},
+	"pro": {
+		ID:                "pro",
+		Name:              "Pro",
+		MaxClients:        200,
+		MaxQuotesPerMonth: 300,
+		MaxUsers:          3,
+		Features:          []string{FeatureBranding, FeatureTemplates},
+	},
+	"business": {
+		ID:                "business",
+		Name:              "Business",
+		MaxClients:        Unlimited,
+		MaxQuotesPerMonth: Unlimited,
+		MaxUsers:          20,
+		Features:          []string{FeatureBranding, FeatureTemplates, FeatureClientImport},
+	},
+}
+
+// Get returns the plan with the given ID, falling back to the default plan
+// when the ID is unknown so stale or tampered claims never grant more.
+func Get(id string) Plan {
+	if p, ok := catalog[id]; ok {
+		return p
+	}
+	return catalog[DefaultPlanID]
+}
+
+// FromRequest resolves the plan carried by the authenticated JWT.
+func FromRequest(r *http.Request) Plan {
+	id, _ := auth.PlanFromCtx(r)
+	return Get(id)
+}
+
+// Has reports whether the plan grants the feature.
+func (p Plan) Has(feature string) bool {
+	for _, f := range p.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAddClients reports whether n more clients fit when used already exist.
+func (p Plan) CanAddClients(used, n int) bool {
+	return withinLimit(p.MaxClients, used+n)
+}
+
+// CanCreateQuote reports whether another quote fits in the monthly allowance.
+func (p Plan) CanCreateQuote(usedThisMonth int) bool {
+	return withinLimit(p.MaxQuotesPerMonth, usedThisMonth+1)
+}
+
+func withinLimit(limit, total int) bool {
+	return limit == Unlimited || total <= limit
+}
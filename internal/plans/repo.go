@@ -0,0 +1,38 @@
+package plans
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// querier is satisfied by *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// LockOwner locks the owner's user row until tx ends. Handlers enforcing a
+// limit take it before counting, so concurrent requests cannot both pass the
+// check and exceed the limit together.
+func LockOwner(ctx context.Context, tx pgx.Tx, ownerID uuid.UUID) error {
+	var one int
+	return tx.QueryRow(ctx, `SELECT 1 FROM users WHERE id=$1 FOR UPDATE`, ownerID).Scan(&one)
+}
+
+// CountClients returns how many clients the owner has.
+func CountClients(ctx context.Context, db querier, ownerID uuid.UUID) (int, error) {
+	var n int
+	err := db.QueryRow(ctx, `SELECT COUNT(*) FROM clients WHERE owner_id=$1`, ownerID).Scan(&n)
+	return n, err
+}
+
+// CountQuotesThisMonth returns how many quotes the owner created in the current calendar month.
+func CountQuotesThisMonth(ctx context.Context, db querier, ownerID uuid.UUID) (int, error) {
+	var n int
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM quotes
+		WHERE owner_id = $1 AND date_trunc('month', created_at) = date_trunc('month', now())
+	`, ownerID).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,64 @@
+package quotes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/roblesvargas97/estimago/internal/catalog"
+)
+
+// fromCatalog fills the blank fields of an item that references a catalog
+// item: kind, name, unit, unit_cost, and unit_price when it is absent. Fields
+// the caller set, a zero price included, are kept as overrides, and the SKU
+// is copied so the quote carries a full snapshot.
+//
+// Filled fields are stored with the line, so recalculating a draft never
+// re-prices it from the current catalog.
+//
+// A line whose catalog item no longer exists keeps its snapshot; only a line
+// that still needs filling fails.
+func fromCatalog(it QuoteItem, defs map[uuid.UUID]catalog.Item) (QuoteItem, error) {
+	if it.CatalogItemID == nil {
+		return it, nil
+	}
+
+	def, ok := defs[*it.CatalogItemID]
+	if !ok {
+		if strings.TrimSpace(it.Name) == "" {
+			return it, fmt.Errorf("unknown catalog item %s", *it.CatalogItemID)
+		}
+		return it, nil
+	}
+
+	if strings.TrimSpace(it.Kind) == "" {
+		it.Kind = def.Kind
+	}
+	if strings.TrimSpace(it.Name) == "" {
+		it.Name = def.Name
+	}
+	if strings.TrimSpace(it.Unit) == "" {
+		it.Unit = def.Unit
+	}
+	if it.UnitPrice == nil {
+		p := def.SellPrice
+		it.UnitPrice = &p
+	}
+	if it.UnitCost == nil {
+		c := def.Cost
+		it.UnitCost = &c
+	}
+	it.SKU = def.SKU
+	return it, nil
+}
+
+// catalogIDsOf returns every catalog item id referenced by the items.
+func catalogIDsOf(items []QuoteItem) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, it := range items {
+		if it.CatalogItemID != nil {
+			ids = append(ids, *it.CatalogItemID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,94 @@
+package quotes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/clients"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// QuoteContact is the client contact a quote is addressed to, as it was
+// when assigned.
+type QuoteContact struct {
+	ID uuid.UUID `json:"id"`
+	clients.ContactFields
+}
+
+// QuoteAddress is the job site of a quote, as it was when assigned.
+type QuoteAddress struct {
+	ID uuid.UUID `json:"id"`
+	clients.AddressFields
+}
+
+// resolveRecipient snapshots the contact and site address a quote refers
+// to, which must belong to its client. On failure the error has been
+// written and ok is false.
+func resolveRecipient(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID, clientID, contactID, siteID *uuid.UUID) (*QuoteContact, *QuoteAddress, bool) {
+	if clientID == nil && (contactID != nil || siteID != nil) {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "contact_id and site_address_id require client_id")
+		return nil, nil, false
+	}
+
+	var (
+		contact *QuoteContact
+		site    *QuoteAddress
+	)
+
+	if contactID != nil {
+		c, err := clients.GetContact(r.Context(), pool, ownerID, *clientID, *contactID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "contact not found for this client")
+			return nil, nil, false
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return nil, nil, false
+		}
+		contact = &QuoteContact{ID: c.ID, ContactFields: c.ContactFields}
+	}
+
+	if siteID != nil {
+		a, err := clients.GetAddress(r.Context(), pool, ownerID, *clientID, *siteID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "address not found for this client")
+			return nil, nil, false
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return nil, nil, false
+		}
+		site = &QuoteAddress{ID: a.ID, AddressFields: a.AddressFields}
+	}
+
+	return contact, site, true
+}
+
+// addressLines formats an address for documents, one line per part.
+func addressLines(a clients.AddressFields) []string {
+	lines := []string{}
+	for _, s := range []*string{a.Label, &a.Line1, a.Line2} {
+		if s != nil && *s != "" {
+			lines = append(lines, *s)
+		}
+	}
+
+	city := []string{}
+	for _, s := range []*string{a.City, a.State, a.PostalCode} {
+		if s != nil && *s != "" {
+			city = append(city, *s)
+		}
+	}
+	if len(city) > 0 {
+		lines = append(lines, strings.Join(city, ", "))
+	}
+
+	if a.Country != nil && *a.Country != "" {
+		lines = append(lines, *a.Country)
+	}
+	return lines
+}
@@ -0,0 +1,42 @@
+package quotes
+
+import (
+	"errors"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+// Discount kinds.
+const (
+	DiscountPct   = "pct"
+	DiscountFixed = "fixed"
+)
+
+// Discount is taken off a line or, before tax, off the whole quote. A pct
+// discount is a percentage of the amount; a fixed one is an amount in the
+// quote's currency.
+type Discount struct {
+	Type  string        `json:"type"`
+	Value money.Decimal `json:"value"`
+}
+
+// off returns how much d takes off amount.
+func (d Discount) off(amount money.Decimal) (money.Decimal, error) {
+	switch d.Type {
+	case DiscountPct:
+		if !pctInRange(d.Value) {
+			return money.Zero, errors.New("pct discount must be between 0 and 100")
+		}
+		return amount.Percent(d.Value), nil
+	case DiscountFixed:
+		if d.Value.Sign() < 0 {
+			return money.Zero, errors.New("fixed discount must be >= 0")
+		}
+		if d.Value.Cmp(amount) > 0 {
+			return money.Zero, errors.New("fixed discount exceeds the amount it applies to")
+		}
+		return d.Value, nil
+	default:
+		return money.Zero, errors.New("discount type must be pct or fixed")
+	}
+}
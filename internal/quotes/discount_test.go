@@ -0,0 +1,177 @@
+package quotes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+func TestDiscountOff(t *testing.T) {
+	tests := []struct {
+		d      Discount
+		amount string
+		want   string // "" for an error
+	}{
+		{Discount{DiscountPct, money.MustParse("10")}, "200", "20"},
+		{Discount{DiscountPct, money.MustParse("0")}, "200", "0"},
+		{Discount{DiscountPct, money.MustParse("100")}, "200", "200"},
+		{Discount{DiscountPct, money.MustParse("12.5")}, "0.10", "0.0125"},
+		{Discount{DiscountPct, money.MustParse("100.01")}, "200", ""},
+		{Discount{DiscountPct, money.MustParse("-1")}, "200", ""},
+
+		{Discount{DiscountFixed, money.MustParse("50")}, "200", "50"},
+		{Discount{DiscountFixed, money.MustParse("200.00")}, "200", "200"},
+		{Discount{DiscountFixed, money.MustParse("200.01")}, "200", ""},
+		{Discount{DiscountFixed, money.MustParse("1")}, "0", ""},
+		{Discount{DiscountFixed, money.MustParse("-1")}, "200", ""},
+
+		{Discount{"percent", money.MustParse("10")}, "200", ""},
+		{Discount{}, "200", ""},
+	}
+	for _, tt := range tests {
+		got, err := tt.d.off(money.MustParse(tt.amount))
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%+v off %s = %s, want error", tt.d, tt.amount, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%+v off %s: %v", tt.d, tt.amount, err)
+		case tt.want != "" && !got.Equal(money.MustParse(tt.want)):
+			t.Errorf("%+v off %s = %s, want %s", tt.d, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestCalcTotalsDiscounts(t *testing.T) {
+	iva := testTax("IVA", "16", false, false)
+	defs := taxMap(iva)
+	pct := func(v string) *Discount { return &Discount{Type: DiscountPct, Value: money.MustParse(v)} }
+	fixed := func(v string) *Discount { return &Discount{Type: DiscountFixed, Value: money.MustParse(v)} }
+
+	tests := []struct {
+		name                        string
+		in                          CreateQuoteIn
+		gross, discount, net, total string
+		lineTotals                  []string
+	}{
+		{
+			name: "line and quote discounts",
+			in: CreateQuoteIn{
+				Items:    []QuoteItem{{Name: "A", Qty: money.MustParse("2"), UnitPrice: price("50"), Discount: pct("10")}},
+				Discount: fixed("15"),
+			},
+			gross: "100.00", discount: "25.00", net: "75.00", total: "75.00", lineTotals: []string{"90.00"},
+		},
+		{
+			// Margin applies after line discounts, the quote discount after margin.
+			name: "margin",
+			in: CreateQuoteIn{
+				Items:     []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100"), Discount: pct("10")}},
+				MarginPct: money.MustParse("10"),
+				Discount:  fixed("99"),
+			},
+			gross: "110.00", discount: "110.00", net: "0.00", total: "0.00", lineTotals: []string{"90.00"},
+		},
+		{
+			name: "quote discount before tax",
+			in: CreateQuoteIn{
+				Items:    []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100")}},
+				TaxIDs:   []uuid.UUID{iva.ID},
+				Discount: pct("10"),
+			},
+			gross: "100.00", discount: "10.00", net: "90.00", total: "104.40", lineTotals: []string{"100.00"},
+		},
+		{
+			// The quote discount is shared by the lines in proportion, so the
+			// exempt line takes half of it.
+			name: "quote discount over mixed taxes",
+			in: CreateQuoteIn{
+				Items: []QuoteItem{
+					{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100")},
+					{Name: "Permit", Qty: money.MustParse("1"), UnitPrice: price("100"), TaxIDs: &[]uuid.UUID{}},
+				},
+				TaxIDs:   []uuid.UUID{iva.ID},
+				Discount: fixed("50"),
+			},
+			gross: "200.00", discount: "50.00", net: "150.00", total: "162.00", lineTotals: []string{"100.00", "100.00"},
+		},
+		{
+			name: "line discount of the whole line",
+			in: CreateQuoteIn{
+				Items: []QuoteItem{
+					{Name: "A", Qty: money.MustParse("3"), UnitPrice: price("10"), Discount: fixed("30")},
+					{Name: "B", Qty: money.MustParse("1"), UnitPrice: price("5")},
+				},
+			},
+			gross: "35.00", discount: "30.00", net: "5.00", total: "5.00", lineTotals: []string{"0.00", "5.00"},
+		},
+	}
+	for _, tt := range tests {
+		tt.in.Currency = "MXN"
+		b, err := calcTotals(tt.in, calcContext{Mode: money.RoundHalfUp, Taxes: defs})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := []string{b.Gross.String(), b.Discount.String(), b.Net.String(), b.Total.String()}
+		want := []string{tt.gross, tt.discount, tt.net, tt.total}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: gross, discount, net, total = %v, want %v", tt.name, got, want)
+		}
+		for i, lt := range tt.lineTotals {
+			if b.Items[i].LineTotal.String() != lt {
+				t.Errorf("%s: items[%d].line_total = %s, want %s", tt.name, i, b.Items[i].LineTotal, lt)
+			}
+		}
+	}
+}
+
+func TestCalcTotalsDiscountErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   CreateQuoteIn
+		want string
+	}{
+		{
+			name: "fixed line discount above the line",
+			in: CreateQuoteIn{Items: []QuoteItem{
+				{Name: "A", Qty: money.MustParse("2"), UnitPrice: price("10"), Discount: &Discount{Type: DiscountFixed, Value: money.MustParse("20.01")}},
+			}},
+			want: "items[0].discount: fixed discount exceeds the amount it applies to",
+		},
+		{
+			// 100 - 10% = 90, + 10% margin = 99
+			name: "fixed quote discount above the discounted amount",
+			in: CreateQuoteIn{
+				Items:     []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100"), Discount: &Discount{Type: DiscountPct, Value: money.MustParse("10")}}},
+				MarginPct: money.MustParse("10"),
+				Discount:  &Discount{Type: DiscountFixed, Value: money.MustParse("99.01")},
+			},
+			want: "discount: fixed discount exceeds the amount it applies to",
+		},
+		{
+			name: "pct quote discount above 100",
+			in: CreateQuoteIn{
+				Items:    []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100")}},
+				Discount: &Discount{Type: DiscountPct, Value: money.MustParse("101")},
+			},
+			want: "discount: pct discount must be between 0 and 100",
+		},
+		{
+			name: "unknown discount type",
+			in: CreateQuoteIn{Items: []QuoteItem{
+				{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100"), Discount: &Discount{Type: "amount", Value: money.MustParse("1")}},
+			}},
+			want: "items[0].discount: discount type must be pct or fixed",
+		},
+	}
+	for _, tt := range tests {
+		tt.in.Currency = "MXN"
+		_, err := calcTotals(tt.in, calcContext{Mode: money.RoundHalfUp})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
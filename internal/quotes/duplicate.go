@@ -0,0 +1,72 @@
+package quotes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// DuplicateQuote copies a quote of any status into a new draft, optionally
+// for another client. The copy is validated and recalculated like a new
+// quote, so it uses the account's current tax definitions and rounding,
+// gets a fresh validity date and counts against the monthly quote limit.
+// Taxes deleted since keep the definition the source quote was saved with.
+func DuplicateQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var body DuplicateQuoteIn
+		// The body is optional.
+		if err := utils.DecodeJSON(w, r, &body); err != nil && !errors.Is(err, utils.ErrEmptyBody) {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		var src Quote
+		err = scanQuote(pool.QueryRow(r.Context(), `SELECT `+quoteColumns+` FROM quotes WHERE id=$1 AND owner_id=$2`, id, ownerID), &src)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+				return
+			}
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		in, err := quoteInOf(src)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "parse_error", "stored items invalid JSON")
+			return
+		}
+		if body.ClientID != nil {
+			// Contacts and addresses belong to the source's client.
+			if in.ClientID == nil || *in.ClientID != *body.ClientID {
+				in.ContactID, in.SiteAddressID = nil, nil
+			}
+			in.ClientID = body.ClientID
+		}
+
+		p, ok := prepareQuote(w, r, pool, ownerID, &in)
+		if !ok {
+			return
+		}
+
+		insertDraft(w, r, pool, ownerID, in, p, &src.ID)
+	}
+}
@@ -0,0 +1,82 @@
+package quotes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// today returns the current date (UTC midnight), the granularity of valid_until.
+func today() time.Time {
+	y, m, d := time.Now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// parseValidUntil parses a YYYY-MM-DD validity date, rejecting past dates.
+func parseValidUntil(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, errors.New("valid_until must be a date in YYYY-MM-DD format")
+	}
+	if t.Before(today()) {
+		return time.Time{}, errors.New("valid_until cannot be in the past")
+	}
+	return t, nil
+}
+
+// isExpired reports whether a validity date has passed.
+func isExpired(validUntil *time.Time) bool {
+	return validUntil != nil && validUntil.Before(today())
+}
+
+// ExpireDue moves every sent quote whose validity date has passed to
+// 'expired' and logs the transition. It returns how many quotes expired.
+func ExpireDue(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	tag, err := pool.Exec(ctx, `
+		WITH expired AS (
+			UPDATE quotes SET status=$1, updated_at=now()
+			WHERE status=$2 AND valid_until < CURRENT_DATE
+			RETURNING id
+		)
+		INSERT INTO quote_status_events (quote_id, from_status, to_status, actor_type)
+		SELECT id, $2, $1, $3 FROM expired
+	`, StatusExpired, StatusSent, actorSystem)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunExpirySweeper calls ExpireDue every interval until ctx is cancelled.
+func RunExpirySweeper(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	sweep := func() {
+		n, err := ExpireDue(ctx, pool)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error al expirar cotizaciones: %v", err)
+			}
+			return
+		}
+		if n > 0 {
+			log.Printf("⌛ %d cotizaciones expiradas", n)
+		}
+	}
+
+	sweep()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package quotes
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/units"
+)
+
+// Measurement is a quote-level figure, such as a floor area, that item
+// quantities can be computed from.
+type Measurement struct {
+	Value money.Decimal `json:"value"`
+	Unit  string        `json:"unit"`
+}
+
+const (
+	maxFormulaLen   = 200
+	maxFormulaDepth = 32
+
+	// qtyPlaces is the precision quantities computed from formulas are
+	// rounded to; use ceil() in the formula to buy whole units.
+	qtyPlaces = 4
+)
+
+var measurementName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,39}$`)
+
+// resolveMeasurements validates measurement names and units, normalizing
+// units to their registry code.
+func resolveMeasurements(in map[string]Measurement) (map[string]Measurement, error) {
+	out := make(map[string]Measurement, len(in))
+	for name, m := range in {
+		if !measurementName.MatchString(name) {
+			return nil, fmt.Errorf("measurements: %q is not a valid name (letters, digits and _)", name)
+		}
+		if _, isFunc := formulaFuncs[strings.ToLower(name)]; isFunc {
+			return nil, fmt.Errorf("measurements: %q is a reserved name", name)
+		}
+		if m.Value.Sign() < 0 {
+			return nil, fmt.Errorf("measurements.%s: value must be >= 0", name)
+		}
+		unit, err := units.Normalize(m.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("measurements.%s: %w", name, err)
+		}
+		out[name] = Measurement{Value: m.Value, Unit: unit}
+	}
+	return out, nil
+}
+
+// formulaQty evaluates an item's quantity formula. A measurement in a unit
+// of the same kind as the item's (m² and ft²) is converted to the item's
+// unit before it is used; any other is used as entered.
+func formulaQty(formula, unit string, ms map[string]Measurement) (money.Decimal, error) {
+	if len(formula) > maxFormulaLen {
+		return money.Zero, fmt.Errorf("must be at most %d characters", maxFormulaLen)
+	}
+	target, hasUnit := units.Lookup(unit)
+
+	p := &formulaParser{src: formula, vars: func(name string) (*big.Rat, error) {
+		m, ok := ms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown measurement %q", name)
+		}
+		v := m.Value.Rat()
+		if from, ok := units.Lookup(m.Unit); ok && hasUnit && units.Convertible(from, target) {
+			return units.Convert(v, from, target)
+		}
+		return v, nil
+	}}
+
+	v, err := p.parse()
+	if err != nil {
+		return money.Zero, err
+	}
+	if v.Sign() < 0 {
+		return money.Zero, errors.New("evaluates to a negative quantity")
+	}
+	return money.FromRat(v, qtyPlaces, money.RoundHalfUp).Trim(), nil
+}
+
+// formulaFuncs are the functions formulas can call, by arity (-1: one or more).
+var formulaFuncs = map[string]int{"ceil": 1, "floor": 1, "round": -1, "min": -1, "max": -1}
+
+// formulaParser - Evaluates quantity formulas with exact rational arithmetic
+// Purpose: Lets a line's quantity follow the quote's measurements (area * 1.1 for waste)
+// Advantages:
+//   - Safe: a fixed grammar of numbers, measurements, + - * / ( ) and a few functions
+//   - No floating point; the result is rounded once, to qtyPlaces
+//   - Bounded input length and nesting depth
+//
+// Weaknesses:
+//   - No unit arithmetic: length * length is not recognized as an area
+//
+// Grammar:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = [ "-" | "+" ] unary | primary
+//	primary = number | name | name "(" expr { "," expr } ")" | "(" expr ")"
+type formulaParser struct {
+	src   string
+	pos   int
+	depth int
+	vars  func(name string) (*big.Rat, error)
+}
+
+func (p *formulaParser) parse() (*big.Rat, error) {
+	v, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos+1)
+	}
+	return v, nil
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept consumes c if it is the next non-space character.
+func (p *formulaParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *formulaParser) expr() (*big.Rat, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxFormulaDepth {
+		return nil, errors.New("is nested too deeply")
+	}
+
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept('+'):
+			w, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			v.Add(v, w)
+		case p.accept('-'):
+			w, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			v.Sub(v, w)
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *formulaParser) term() (*big.Rat, error) {
+	v, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept('*'):
+			w, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			v.Mul(v, w)
+		case p.accept('/'):
+			w, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			if w.Sign() == 0 {
+				return nil, errors.New("divides by zero")
+			}
+			v.Quo(v, w)
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *formulaParser) unary() (*big.Rat, error) {
+	switch {
+	case p.accept('-'):
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return v.Neg(v), nil
+	case p.accept('+'):
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *formulaParser) primary() (*big.Rat, error) {
+	if p.accept('(') {
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		return v, nil
+	}
+
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && isNameByte(p.src[p.pos], p.pos > start) {
+		p.pos++
+	}
+	if p.pos > start {
+		name := p.src[start:p.pos]
+		if p.accept('(') {
+			return p.call(strings.ToLower(name))
+		}
+		return p.vars(name)
+	}
+
+	for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.src) {
+			return nil, errors.New("ends unexpectedly")
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos+1)
+	}
+	d, err := money.Parse(p.src[start:p.pos])
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return d.Rat(), nil
+}
+
+func isNameByte(c byte, notFirst bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || notFirst && c >= '0' && c <= '9'
+}
+
+// call evaluates the arguments of a function whose "(" was consumed.
+func (p *formulaParser) call(name string) (*big.Rat, error) {
+	arity, ok := formulaFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+
+	args := []*big.Rat{}
+	for {
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if p.accept(')') {
+			break
+		}
+		if !p.accept(',') {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+	}
+	if arity > 0 && len(args) != arity {
+		return nil, fmt.Errorf("%s takes %d argument(s)", name, arity)
+	}
+
+	switch name {
+	case "ceil":
+		v := floor(new(big.Rat).Neg(args[0]))
+		return v.Neg(v), nil
+	case "floor":
+		return floor(args[0]), nil
+	case "round":
+		if len(args) > 2 {
+			return nil, errors.New("round takes 1 or 2 arguments")
+		}
+		places := int64(0)
+		if len(args) == 2 {
+			if !args[1].IsInt() || args[1].Num().Int64() < 0 || args[1].Num().Int64() > qtyPlaces {
+				return nil, fmt.Errorf("round: places must be a whole number from 0 to %d", qtyPlaces)
+			}
+			places = args[1].Num().Int64()
+		}
+		return money.FromRat(args[0], int32(places), money.RoundHalfUp).Rat(), nil
+	default: // min, max
+		v := args[0]
+		for _, a := range args[1:] {
+			if c := a.Cmp(v); name == "min" && c < 0 || name == "max" && c > 0 {
+				v = a
+			}
+		}
+		return v, nil
+	}
+}
+
+// floor rounds toward negative infinity.
+func floor(v *big.Rat) *big.Rat {
+	q := new(big.Int).Div(v.Num(), v.Denom()) // Euclidean: floor for a positive denominator
+	return new(big.Rat).SetInt(q)
+}
@@ -0,0 +1,189 @@
+package quotes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+func TestFormulaQty(t *testing.T) {
+	ms := map[string]Measurement{
+		"area":  {Value: money.MustParse("20"), Unit: "m2"},
+		"perim": {Value: money.MustParse("18.5"), Unit: "m"},
+		"doors": {Value: money.MustParse("3"), Unit: "pc"},
+	}
+
+	tests := []struct {
+		formula string
+		unit    string
+		want    string
+	}{
+		// precedence and associativity
+		{"1 + 2 * 3", "", "7"},
+		{"(1 + 2) * 3", "", "9"},
+		{"10 - 4 - 3", "", "3"},
+		{"12 / 4 / 3", "", "1"},
+		{"2 * 3 / 4", "", "1.5"},
+
+		// unary minus
+		{"-2 * -3", "", "6"},
+		{"--2", "", "2"},
+		{"2 - -1", "", "3"},
+		{"-(1 - 3)", "", "2"},
+		{"+4", "", "4"},
+
+		// exact arithmetic, rounded once to qtyPlaces
+		{"10 / 3", "", "3.3333"},
+		{"2 / 3", "", "0.6667"},
+		{"0.1 + 0.2", "", "0.3"},
+
+		// functions
+		{"ceil(2.1)", "", "3"},
+		{"ceil(2)", "", "2"},
+		{"ceil(-2.1) + 5", "", "3"},
+		{"floor(2.9)", "", "2"},
+		{"floor(-2.1) + 5", "", "2"},
+		{"CEIL(2.1)", "", "3"},
+		{"round(2.5)", "", "3"},
+		{"round(2.345, 2)", "", "2.35"},
+		{"round(1.23456, 4)", "", "1.2346"},
+		{"round(7.5, 0)", "", "8"},
+		{"min(3, 1, 2)", "", "1"},
+		{"max(3, 1, 2)", "", "3"},
+		{"max(4)", "", "4"},
+
+		// measurements
+		{"area * 1.1", "m2", "22"},
+		{"ceil(area / 2.5)", "m2", "8"},
+		{"perim * 2 + doors", "m", "40"},
+		{"doors", "pc", "3"},
+	}
+	for _, tt := range tests {
+		got, err := formulaQty(tt.formula, tt.unit, ms)
+		if err != nil {
+			t.Errorf("formulaQty(%q): %v", tt.formula, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("formulaQty(%q) = %s, want %s", tt.formula, got, tt.want)
+		}
+	}
+}
+
+func TestFormulaQtyConvertsUnits(t *testing.T) {
+	ms := map[string]Measurement{
+		"floor":  {Value: money.MustParse("100"), Unit: "ft2"},
+		"wall":   {Value: money.MustParse("10"), Unit: "m2"},
+		"run":    {Value: money.MustParse("3"), Unit: "ft"},
+		"weight": {Value: money.MustParse("2"), Unit: "lb"},
+	}
+
+	tests := []struct {
+		formula string
+		unit    string
+		want    string
+	}{
+		{"floor", "m2", "9.2903"},
+		{"wall", "sqft", "107.6391"},
+		{"run", "m", "0.9144"},
+		{"weight", "oz", "32"},
+		// a different kind, or no unit, uses the value as entered
+		{"floor", "m", "100"},
+		{"floor", "", "100"},
+		{"run", "pc", "3"},
+	}
+	for _, tt := range tests {
+		got, err := formulaQty(tt.formula, tt.unit, ms)
+		if err != nil {
+			t.Errorf("formulaQty(%q, %q): %v", tt.formula, tt.unit, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("formulaQty(%q, %q) = %s, want %s", tt.formula, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestFormulaQtyErrors(t *testing.T) {
+	ms := map[string]Measurement{
+		"area": {Value: money.MustParse("20"), Unit: "m2"},
+	}
+
+	tests := []struct {
+		formula string
+		want    string
+	}{
+		{"1 / 0", "divides by zero"},
+		{"area / (2 - 2)", "divides by zero"},
+
+		{"ceil(1, 2)", "ceil takes 1 argument(s)"},
+		{"floor(1, 2)", "floor takes 1 argument(s)"},
+		{"floor()", "unexpected ')'"},
+		{"round(1, 2, 3)", "round takes 1 or 2 arguments"},
+		{"round(1, 5)", "places must be a whole number from 0 to 4"},
+		{"round(1, -1)", "places must be a whole number from 0 to 4"},
+		{"round(1, 1.5)", "places must be a whole number from 0 to 4"},
+
+		{"sqrt(4)", `unknown function "sqrt"`},
+		{"width * 2", `unknown measurement "width"`},
+		{"Area", `unknown measurement "Area"`},
+
+		{"1 +", "ends unexpectedly"},
+		{"(1 + 2", "missing )"},
+		{"max(1, 2", "missing )"},
+		{"2 3", "unexpected '3' at position 3"},
+		{"1..2", `invalid number "1..2"`},
+		{"2 % 3", "unexpected '%'"},
+		{"1 - 2", "evaluates to a negative quantity"},
+
+		{strings.Repeat("(", 32) + "1" + strings.Repeat(")", 32), "is nested too deeply"},
+		{strings.Repeat("1+", 100) + "1", "must be at most 200 characters"},
+	}
+	for _, tt := range tests {
+		_, err := formulaQty(tt.formula, "m2", ms)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("formulaQty(%q) error = %v, want %q", tt.formula, err, tt.want)
+		}
+	}
+}
+
+func TestFormulaQtyLimits(t *testing.T) {
+	deep := strings.Repeat("(", maxFormulaDepth-1) + "1" + strings.Repeat(")", maxFormulaDepth-1)
+	if got, err := formulaQty(deep, "", nil); err != nil || got.String() != "1" {
+		t.Errorf("formulaQty at max depth = %v, %v; want 1", got, err)
+	}
+
+	long := strings.Repeat("1+", (maxFormulaLen-1)/2) + "1"
+	if len(long) != maxFormulaLen-1 && len(long) != maxFormulaLen {
+		t.Fatalf("long formula has %d characters", len(long))
+	}
+	if got, err := formulaQty(long, "", nil); err != nil || got.String() != "100" {
+		t.Errorf("formulaQty at max length = %v, %v; want 100", got, err)
+	}
+}
+
+func TestResolveMeasurements(t *testing.T) {
+	got, err := resolveMeasurements(map[string]Measurement{
+		"area":   {Value: money.MustParse("12"), Unit: "Sq Ft"},
+		"length": {Value: money.MustParse("3"), Unit: "metros"},
+	})
+	if err != nil {
+		t.Fatalf("resolveMeasurements: %v", err)
+	}
+	if got["area"].Unit != "ft2" || got["length"].Unit != "m" {
+		t.Errorf("units = %q, %q; want ft2, m", got["area"].Unit, got["length"].Unit)
+	}
+
+	for name, m := range map[string]Measurement{
+		"1area": {Value: money.MustParse("1"), Unit: "m2"},
+		"a-b":   {Value: money.MustParse("1"), Unit: "m2"},
+		"Ceil":  {Value: money.MustParse("1"), Unit: "m2"},
+		"neg":   {Value: money.MustParse("-1"), Unit: "m2"},
+		"bad":   {Value: money.MustParse("1"), Unit: "furlong"},
+	} {
+		if _, err := resolveMeasurements(map[string]Measurement{name: m}); err == nil {
+			t.Errorf("resolveMeasurements(%q) succeeded, want error", name)
+		}
+	}
+}
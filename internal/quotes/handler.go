@@ -15,119 +15,240 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/accounts"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/catalog"
+	"github.com/roblesvargas97/estimago/internal/clients"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/plans"
+	"github.com/roblesvargas97/estimago/internal/taxes"
+	"github.com/roblesvargas97/estimago/internal/units"
 	"github.com/roblesvargas97/estimago/internal/utils"
 )
 
 func PostQuote(pool *pgxpool.Pool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		var in CreateQuoteIn
-
-		if err := utils.DecodeJSON(w, r, &in); err != nil {
-			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
 			return
 		}
 
-		if len(in.Items) == 0 {
-			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "items: at least one item is required")
+		in, ok := decodeQuoteIn(w, r, pool, ownerID)
+		if !ok {
 			return
-
 		}
 
-		if in.MarginPct < 0 || in.MarginPct > 100 || in.TaxPct < 0 || in.TaxPct > 100 {
-			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "margin_pct and tax_pct must be between 0 and 100")
+		p, ok := prepareQuote(w, r, pool, ownerID, &in)
+		if !ok {
 			return
 		}
 
-		if len(in.Currency) != 3 {
-			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "currency must be a valid 3-letter ISO code")
-			return
-		}
+		insertDraft(w, r, pool, ownerID, in, p, nil)
+	}
+}
 
-		var monthCount int
+// insertDraft stores a prepared quote as a new draft with its first revision
+// and writes it with 201. It enforces the plan's monthly quote limit;
+// sourceID records the quote it was duplicated from, if any.
+func insertDraft(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID, in CreateQuoteIn, p prepared, sourceID *uuid.UUID) {
+	calc, settings := p.calc, p.settings
 
-		if err := pool.QueryRow(r.Context(), `
-			SELECT COUNT(*) FROM quotes
-			WHERE date_trunc('month', created_at) = date_trunc('month', now())
-		`).Scan(&monthCount); err != nil {
-			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
-			return
-		}
+	itemsJSON, err := json.Marshal(calc.Items)
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "marshal_error", "failed to serialize items")
+		return
+	}
 
-		if monthCount >= 10 {
-			utils.WriteErr(w, http.StatusForbidden, "limit_reached", "monthly quote limit reached")
-			return
-		}
+	tx, err := pool.Begin(r.Context())
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	if err := plans.LockOwner(r.Context(), tx, ownerID); err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
 
-		itemsCalculated, subtotalStr, totalStr, err := calcTotals(in)
+	monthCount, err := plans.CountQuotesThisMonth(r.Context(), tx, ownerID)
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
 
-		if err != nil {
-			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
-			return
-		}
+	if plan := plans.FromRequest(r); !plan.CanCreateQuote(monthCount) {
+		utils.WriteErr(w, http.StatusForbidden, "limit_reached", plan.Name+" plan monthly quote limit reached")
+		return
+	}
 
-		itemsJSON, err := json.Marshal(itemsCalculated)
+	var q Quote
+
+	err = scanQuote(tx.QueryRow(r.Context(), `
+		INSERT INTO quotes (
+			owner_id, client_id, contact_id, site_address_id, contact, site_address, items, measurements, labor_hours, labor_rate, labor_lines, margin_pct, tax_pct,
+			discount, prices_include_tax, gross, discount_amount, net, cost, cost_complete, sections, tax_ids, taxes, subtotal, total,
+			currency, rounding_mode, notes, valid_until, template_id, source_quote_id, status, revision
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,'draft',1)
+		RETURNING `+quoteColumns,
+		ownerID,
+		in.ClientID,
+		in.ContactID,
+		in.SiteAddressID,
+		p.contact,
+		p.site,
+		itemsJSON,
+		calc.Measurements,
+		in.LaborHours.Round(2),
+		in.LaborRate,
+		calc.LaborLines,
+		in.MarginPct.Round(2),
+		in.TaxPct.Round(2),
+		in.Discount,
+		in.PricesIncludeTax,
+		calc.Gross,
+		calc.Discount,
+		calc.Net,
+		calc.Cost,
+		calc.CostComplete,
+		calc.Sections,
+		calc.TaxIDs,
+		calc.Taxes,
+		calc.Net,
+		calc.Total,
+		in.Currency,
+		settings.RoundingMode,
+		in.Notes,
+		p.validUntil,
+		in.TemplateID,
+		sourceID,
+	), &q)
+
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
 
-		var q Quote
+	if err := insertRevision(r.Context(), tx, q, &ownerID); err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
 
-		err = pool.QueryRow(r.Context(), `
-			INSERT INTO quotes (
-				client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-				subtotal, total, currency, notes, status
-			) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'draft')
-			RETURNING id, client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-				subtotal, total, currency, notes, public_id, status, created_at, updated_at
-		`, in.ClientID,
-			itemsJSON,
-			fmt.Sprintf("%.2f", in.LaborHours),
-			fmt.Sprintf("%.2f", in.LaborRate),
-			fmt.Sprintf("%.2f", in.MarginPct),
-			fmt.Sprintf("%.2f", in.TaxPct),
-			subtotalStr,
-			totalStr,
-			strings.ToUpper(in.Currency),
-			in.Notes,
-		).Scan(
-			&q.ID,
-			&q.ClientID,
-			&q.Items,
-			&q.LaborHours,
-			&q.LaborRate,
-			&q.MarginPct,
-			&q.TaxPct,
-			&q.Subtotal,
-			&q.Total,
-			&q.Currency,
-			&q.Notes,
-			&q.PublicID,
-			&q.Status, &q.CreatedAt, &q.UpdatedAt,
-		)
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, q)
+}
+
+// prepared is a validated quote input with everything derived from it.
+type prepared struct {
+	settings   accounts.Settings
+	validUntil time.Time
+	calc       breakdown
+	contact    *QuoteContact
+	site       *QuoteAddress
+}
+
+// prepareQuote runs the validation shared by PostQuote and PreviewQuote and
+// computes the totals. It only reads from the database; on failure the error
+// has been written and ok is false. in.Currency is normalized in place.
+func prepareQuote(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID, in *CreateQuoteIn) (prepared, bool) {
+	if len(in.Items) == 0 {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "items: at least one item is required")
+		return prepared{}, false
+	}
+
+	if !pctInRange(in.MarginPct) || !pctInRange(in.TaxPct) {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "margin_pct and tax_pct must be between 0 and 100")
+		return prepared{}, false
+	}
+
+	cur, ok := money.LookupCurrency(in.Currency)
+	if !ok {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "currency must be a valid ISO 4217 code")
+		return prepared{}, false
+	}
+	in.Currency = cur.Code
 
+	if in.ClientID != nil {
+		exists, err := clients.Exists(r.Context(), pool, ownerID, *in.ClientID)
 		if err != nil {
 			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
-			return
+			return prepared{}, false
+		}
+		if !exists {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+			return prepared{}, false
 		}
+	}
+
+	contact, site, ok := resolveRecipient(w, r, pool, ownerID, in.ClientID, in.ContactID, in.SiteAddressID)
+	if !ok {
+		return prepared{}, false
+	}
+
+	settings, err := accounts.Get(r.Context(), pool, ownerID)
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return prepared{}, false
+	}
+
+	validUntil := today().AddDate(0, 0, settings.DefaultValidDays)
+	if in.ValidUntil != nil {
+		validUntil, err = parseValidUntil(*in.ValidUntil)
+		if err != nil {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+			return prepared{}, false
+		}
+	}
 
-		utils.WriteJSON(w, http.StatusCreated, q)
+	taxDefs, err := taxes.GetMany(r.Context(), pool, ownerID, taxIDsOf(*in))
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return prepared{}, false
+	}
+
+	catalogDefs, err := catalog.GetMany(r.Context(), pool, ownerID, catalogIDsOf(in.Items))
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return prepared{}, false
+	}
 
+	calc, err := calcTotals(*in, calcContext{
+		Mode:       settings.RoundingMode,
+		Taxes:      taxDefs,
+		Catalog:    catalogDefs,
+		LaborRates: settings.LaborRates,
+	})
+	if err != nil {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return prepared{}, false
 	}
+
+	return prepared{settings: settings, validUntil: validUntil, calc: calc, contact: contact, site: site}, true
 }
 
 func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
 		query := r.URL.Query()
 
 		statusParam := strings.TrimSpace(query.Get("status"))
 		var statuses []string
 		if statusParam != "" {
 			rawStatuses := strings.Split(statusParam, ",")
-			allowed := map[string]struct{}{"draft": {}, "sent": {}, "accepted": {}, "rejected": {}}
 			for _, st := range rawStatuses {
 				trimmed := strings.ToLower(strings.TrimSpace(st))
 				if trimmed == "" {
 					continue
 				}
-				if _, ok := allowed[trimmed]; !ok {
+				if !isKnownStatus(trimmed) {
 					utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid status filter")
 					return
 				}
@@ -184,8 +305,8 @@ func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 
 		offset := (page - 1) * limit
 
-		conditions := []string{}
-		args := []any{}
+		conditions := []string{"owner_id = $1"}
+		args := []any{ownerID}
 
 		if len(statuses) > 0 {
 			conditions = append(conditions, fmt.Sprintf("status = ANY($%d::text[])", len(args)+1))
@@ -212,10 +333,7 @@ func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 			args = append(args, createdTo)
 		}
 
-		baseSQL := "FROM quotes"
-		if len(conditions) > 0 {
-			baseSQL += " WHERE " + strings.Join(conditions, " AND ")
-		}
+		baseSQL := "FROM quotes WHERE " + strings.Join(conditions, " AND ")
 
 		var total int
 		countSQL := "SELECT COUNT(*) " + baseSQL
@@ -224,8 +342,7 @@ func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
-		dataSQL := "SELECT id, client_id, items, labor_hours, labor_rate, margin_pct, tax_pct, " +
-			"subtotal, total, currency, notes, public_id, status, created_at, updated_at " + baseSQL +
+		dataSQL := "SELECT " + quoteColumns + " " + baseSQL +
 			fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
 
 		dataArgs := append(append([]any{}, args...), limit, offset)
@@ -240,23 +357,7 @@ func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 		outs := []Quote{}
 		for rows.Next() {
 			var q Quote
-			if err := rows.Scan(
-				&q.ID,
-				&q.ClientID,
-				&q.Items,
-				&q.LaborHours,
-				&q.LaborRate,
-				&q.MarginPct,
-				&q.TaxPct,
-				&q.Subtotal,
-				&q.Total,
-				&q.Currency,
-				&q.Notes,
-				&q.PublicID,
-				&q.Status,
-				&q.CreatedAt,
-				&q.UpdatedAt,
-			); err != nil {
+			if err := scanQuote(rows, &q); err != nil {
 				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 				return
 			}
@@ -275,6 +376,11 @@ func ListQuotes(pool *pgxpool.Pool) http.HandlerFunc {
 
 func GetQuote(pool *pgxpool.Pool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
 		idStr := strings.TrimSpace(chi.URLParam(r, "id"))
 
 		id, err := uuid.Parse(idStr)
@@ -285,14 +391,7 @@ func GetQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 		var q Quote
 
-		err = pool.QueryRow(r.Context(), `
-                        SELECT id, client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-                               subtotal, total, currency, notes, public_id, status, created_at, updated_at
-                        FROM quotes WHERE id=$1
-                `, id).Scan(
-			&q.ID, &q.ClientID, &q.Items, &q.LaborHours, &q.LaborRate, &q.MarginPct, &q.TaxPct,
-			&q.Subtotal, &q.Total, &q.Currency, &q.Notes, &q.PublicID, &q.Status, &q.CreatedAt, &q.UpdatedAt,
-		)
+		err = scanQuote(pool.QueryRow(r.Context(), `SELECT `+quoteColumns+` FROM quotes WHERE id=$1 AND owner_id=$2`, id, ownerID), &q)
 
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
@@ -310,6 +409,11 @@ func GetQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
 		idStr := strings.TrimSpace(chi.URLParam(r, "id"))
 
 		id, err := uuid.Parse(idStr)
@@ -326,32 +430,51 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 		}
 
 		notesProvided := in.Notes != nil
+		validUntilProvided := in.ValidUntil != nil
 
-		if in.ClientID == nil && in.Items == nil && in.LaborHours == nil && in.LaborRate == nil &&
-			in.MarginPct == nil && in.TaxPct == nil && in.Currency == nil && !notesProvided && in.Status == nil {
+		if in.ClientID == nil && in.ContactID == nil && in.SiteAddressID == nil && in.Items == nil && in.Measurements == nil && in.LaborHours == nil && in.LaborRate == nil && in.LaborLines == nil &&
+			in.MarginPct == nil && in.TaxPct == nil && in.TaxIDs == nil && in.PricesIncludeTax == nil &&
+			in.Currency == nil && in.Discount == nil &&
+			!notesProvided && !validUntilProvided && in.Status == nil {
 			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
 			return
 		}
 
 		var (
-			clientID  *uuid.UUID
-			itemsJSON json.RawMessage
+			clientID     *uuid.UUID
+			contactID    *uuid.UUID
+			siteID       *uuid.UUID
+			itemsJSON    json.RawMessage
+			measurements map[string]Measurement
 			laborHours,
 			laborRate,
 			marginPct,
-			taxPct float64
-			currency string
-			notes    *string
-			status   string
+			taxPct money.Decimal
+			laborLines []LaborLine
+			discount   *Discount
+			taxIDs     []uuid.UUID
+			taxLines   []TaxLine
+			inclTax    bool
+			currency   string
+			notes      *string
+			status     string
 		)
 
-		err = pool.QueryRow(r.Context(), `
-                        SELECT client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-                               currency, notes, status
-                        FROM quotes WHERE id=$1
-                `, id).Scan(
-			&clientID, &itemsJSON, &laborHours, &laborRate, &marginPct, &taxPct,
-			&currency, &notes, &status,
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		err = tx.QueryRow(r.Context(), `
+                        SELECT client_id, contact_id, site_address_id, items, measurements, labor_hours, labor_rate, labor_lines, margin_pct, tax_pct,
+                               discount, tax_ids, taxes, prices_include_tax, currency, notes, status
+                        FROM quotes WHERE id=$1 AND owner_id=$2
+                        FOR UPDATE
+                `, id, ownerID).Scan(
+			&clientID, &contactID, &siteID, &itemsJSON, &measurements, &laborHours, &laborRate, &laborLines, &marginPct, &taxPct,
+			&discount, &taxIDs, &taxLines, &inclTax, &currency, &notes, &status,
 		)
 
 		if err != nil {
@@ -363,11 +486,67 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
+		// Only drafts are editable; a sent quote must be revised first.
+		contentChanged := in.ClientID != nil || in.ContactID != nil || in.SiteAddressID != nil || in.Items != nil || in.Measurements != nil || in.LaborHours != nil || in.LaborRate != nil || in.LaborLines != nil ||
+			in.MarginPct != nil || in.TaxPct != nil || in.TaxIDs != nil || in.PricesIncludeTax != nil ||
+			in.Currency != nil || in.Discount != nil || notesProvided || validUntilProvided
+
+		if contentChanged && status != StatusDraft {
+			utils.WriteErr(w, http.StatusConflict, "quote_locked", "only draft quotes can be edited; revise the quote first")
+			return
+		}
+
 		effectiveClientID := clientID
 		if in.ClientID != nil {
+			exists, err := clients.Exists(r.Context(), pool, ownerID, *in.ClientID)
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			if !exists {
+				utils.WriteErr(w, http.StatusNotFound, "not_found", "client not found")
+				return
+			}
 			effectiveClientID = in.ClientID
 		}
 
+		// A new client drops the old client's contact and site address.
+		effectiveContactID, effectiveSiteID := contactID, siteID
+		recipientChanged := in.ContactID != nil || in.SiteAddressID != nil
+		if in.ClientID != nil && (clientID == nil || *clientID != *in.ClientID) {
+			effectiveContactID, effectiveSiteID = nil, nil
+			recipientChanged = true
+		}
+		for _, f := range []struct {
+			name string
+			raw  *json.RawMessage
+			dst  **uuid.UUID
+		}{{"contact_id", in.ContactID, &effectiveContactID}, {"site_address_id", in.SiteAddressID, &effectiveSiteID}} {
+			if f.raw == nil {
+				continue
+			}
+			*f.dst = nil
+			if string(*f.raw) != "null" {
+				var id uuid.UUID
+				if err := json.Unmarshal(*f.raw, &id); err != nil {
+					utils.WriteErr(w, http.StatusBadRequest, "validation_error", f.name+" must be a uuid or null")
+					return
+				}
+				*f.dst = &id
+			}
+		}
+
+		var (
+			newContact *QuoteContact
+			newSite    *QuoteAddress
+		)
+		if recipientChanged {
+			newContact, newSite, ok = resolveRecipient(w, r, pool, ownerID, effectiveClientID, effectiveContactID, effectiveSiteID)
+			if !ok {
+				return
+			}
+		}
+
 		var items []QuoteItem
 		if err := json.Unmarshal(itemsJSON, &items); err != nil {
 			utils.WriteErr(w, http.StatusInternalServerError, "parse_error", "stored items invalid JSON")
@@ -375,6 +554,7 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 		}
 
 		effectiveItems := items
+		storedUnits := unitsOf(items)
 		if in.Items != nil {
 			if len(*in.Items) == 0 {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "items: at least one item is required")
@@ -383,9 +563,14 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			effectiveItems = *in.Items
 		}
 
+		effectiveMeasurements := measurements
+		if in.Measurements != nil {
+			effectiveMeasurements = *in.Measurements
+		}
+
 		effectiveLaborHours := laborHours
 		if in.LaborHours != nil {
-			if *in.LaborHours < 0 {
+			if in.LaborHours.Sign() < 0 {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "labor_hours must be >= 0")
 				return
 			}
@@ -394,16 +579,21 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 		effectiveLaborRate := laborRate
 		if in.LaborRate != nil {
-			if *in.LaborRate < 0 {
+			if in.LaborRate.Sign() < 0 {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "labor_rate must be >= 0")
 				return
 			}
 			effectiveLaborRate = *in.LaborRate
 		}
 
+		effectiveLaborLines := laborLines
+		if in.LaborLines != nil {
+			effectiveLaborLines = *in.LaborLines
+		}
+
 		effectiveMargin := marginPct
 		if in.MarginPct != nil {
-			if *in.MarginPct < 0 || *in.MarginPct > 100 {
+			if !pctInRange(*in.MarginPct) {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "margin_pct must be between 0 and 100")
 				return
 			}
@@ -412,7 +602,7 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 		effectiveTax := taxPct
 		if in.TaxPct != nil {
-			if *in.TaxPct < 0 || *in.TaxPct > 100 {
+			if !pctInRange(*in.TaxPct) {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "tax_pct must be between 0 and 100")
 				return
 			}
@@ -421,12 +611,35 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 		effectiveCurrency := currency
 		if in.Currency != nil {
-			cur := strings.ToUpper(strings.TrimSpace(*in.Currency))
-			if len(cur) != 3 {
-				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "currency must be a valid 3-letter ISO code")
+			cur, ok := money.LookupCurrency(*in.Currency)
+			if !ok {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "currency must be a valid ISO 4217 code")
 				return
 			}
-			effectiveCurrency = cur
+			effectiveCurrency = cur.Code
+		}
+
+		effectiveTaxIDs := taxIDs
+		if in.TaxIDs != nil {
+			effectiveTaxIDs = *in.TaxIDs
+		}
+
+		effectiveInclTax := inclTax
+		if in.PricesIncludeTax != nil {
+			effectiveInclTax = *in.PricesIncludeTax
+		}
+
+		effectiveDiscount := discount
+		if in.Discount != nil {
+			effectiveDiscount = nil
+			if string(*in.Discount) != "null" {
+				var d Discount
+				if err := json.Unmarshal(*in.Discount, &d); err != nil {
+					utils.WriteErr(w, http.StatusBadRequest, "validation_error", "discount must be an object or null")
+					return
+				}
+				effectiveDiscount = &d
+			}
 		}
 
 		var (
@@ -449,6 +662,21 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			}
 		}
 
+		var newValidUntil *time.Time
+		if validUntilProvided && string(*in.ValidUntil) != "null" {
+			var raw string
+			if err := json.Unmarshal(*in.ValidUntil, &raw); err != nil {
+				utils.WriteErr(w, http.StatusBadRequest, "validation_error", "valid_until must be a date string or null")
+				return
+			}
+			t, err := parseValidUntil(raw)
+			if err != nil {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+				return
+			}
+			newValidUntil = &t
+		}
+
 		effectiveStatus := status
 		var statusUpdated bool
 		if in.Status != nil {
@@ -457,40 +685,78 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "status cannot be empty")
 				return
 			}
-			allowedStatuses := map[string]struct{}{"draft": {}, "sent": {}, "accepted": {}, "rejected": {}}
-			if _, ok := allowedStatuses[s]; !ok {
-				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "status must be one of draft,sent,accepted,rejected")
+			if !isKnownStatus(s) {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "status must be one of draft,sent,accepted,rejected,expired")
 				return
 			}
-			effectiveStatus = s
-			statusUpdated = true
+			if s != status {
+				if err := checkTransition(status, s, actionRespond); err != nil {
+					writeTransitionErr(w, status, s)
+					return
+				}
+				effectiveStatus = s
+				statusUpdated = true
+			}
 		}
 
-		needsRecalc := in.Items != nil || in.LaborHours != nil || in.LaborRate != nil || in.MarginPct != nil || in.TaxPct != nil
+		// A currency change recalculates too: minor units drive rounding.
+		needsRecalc := in.Items != nil || in.Measurements != nil || in.LaborHours != nil || in.LaborRate != nil || in.LaborLines != nil || in.MarginPct != nil ||
+			in.TaxPct != nil || in.TaxIDs != nil || in.PricesIncludeTax != nil || in.Currency != nil || in.Discount != nil
 
 		var (
 			newItemsJSON []byte
-			subtotalStr  string
-			totalStr     string
+			calc         breakdown
+			roundingMode money.RoundingMode
 		)
 
 		if needsRecalc {
-			itemsCalculated, subtotalOut, totalOut, err := calcTotals(CreateQuoteIn{
-				Items:      effectiveItems,
-				LaborHours: effectiveLaborHours,
-				LaborRate:  effectiveLaborRate,
-				MarginPct:  effectiveMargin,
-				TaxPct:     effectiveTax,
+			settings, err := accounts.Get(r.Context(), pool, ownerID)
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			roundingMode = settings.RoundingMode
+
+			calcIn := CreateQuoteIn{
+				Items:            effectiveItems,
+				Measurements:     effectiveMeasurements,
+				LaborHours:       effectiveLaborHours,
+				LaborRate:        effectiveLaborRate,
+				LaborLines:       effectiveLaborLines,
+				MarginPct:        effectiveMargin,
+				TaxPct:           effectiveTax,
+				TaxIDs:           effectiveTaxIDs,
+				PricesIncludeTax: effectiveInclTax,
+				Currency:         effectiveCurrency,
+				Discount:         effectiveDiscount,
+				storedUnits:      storedUnits,
+				storedTaxes:      taxDefsOf(taxLines),
+			}
+
+			taxDefs, err := taxes.GetMany(r.Context(), pool, ownerID, taxIDsOf(calcIn))
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+
+			catalogDefs, err := catalog.GetMany(r.Context(), pool, ownerID, catalogIDsOf(calcIn.Items))
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+
+			calc, err = calcTotals(calcIn, calcContext{
+				Mode:       roundingMode,
+				Taxes:      taxDefs,
+				Catalog:    catalogDefs,
+				LaborRates: settings.LaborRates,
 			})
 			if err != nil {
 				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
 				return
 			}
 
-			subtotalStr = subtotalOut
-			totalStr = totalOut
-
-			newItemsJSON, err = json.Marshal(itemsCalculated)
+			newItemsJSON, err = json.Marshal(calc.Items)
 			if err != nil {
 				utils.WriteErr(w, http.StatusInternalServerError, "marshal_error", "failed to serialize items")
 				return
@@ -507,7 +773,13 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			idx++
 		}
 
-		if in.Items != nil {
+		if recipientChanged {
+			sets = append(sets, fmt.Sprintf("contact_id=$%d, site_address_id=$%d, contact=$%d, site_address=$%d", idx, idx+1, idx+2, idx+3))
+			args = append(args, effectiveContactID, effectiveSiteID, newContact, newSite)
+			idx += 4
+		}
+
+		if needsRecalc {
 			sets = append(sets, fmt.Sprintf("items=$%d", idx))
 			args = append(args, newItemsJSON)
 			idx++
@@ -515,25 +787,25 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 		if in.LaborHours != nil {
 			sets = append(sets, fmt.Sprintf("labor_hours=$%d", idx))
-			args = append(args, fmt.Sprintf("%.2f", effectiveLaborHours))
+			args = append(args, effectiveLaborHours.Round(2))
 			idx++
 		}
 
 		if in.LaborRate != nil {
 			sets = append(sets, fmt.Sprintf("labor_rate=$%d", idx))
-			args = append(args, fmt.Sprintf("%.2f", effectiveLaborRate))
+			args = append(args, effectiveLaborRate)
 			idx++
 		}
 
 		if in.MarginPct != nil {
 			sets = append(sets, fmt.Sprintf("margin_pct=$%d", idx))
-			args = append(args, fmt.Sprintf("%.2f", effectiveMargin))
+			args = append(args, effectiveMargin.Round(2))
 			idx++
 		}
 
 		if in.TaxPct != nil {
 			sets = append(sets, fmt.Sprintf("tax_pct=$%d", idx))
-			args = append(args, fmt.Sprintf("%.2f", effectiveTax))
+			args = append(args, effectiveTax.Round(2))
 			idx++
 		}
 
@@ -549,34 +821,108 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			idx++
 		}
 
+		if validUntilProvided {
+			sets = append(sets, fmt.Sprintf("valid_until=$%d", idx))
+			args = append(args, newValidUntil)
+			idx++
+		}
+
 		if statusUpdated {
 			sets = append(sets, fmt.Sprintf("status=$%d", idx))
 			args = append(args, effectiveStatus)
 			idx++
 		}
 
+		if in.PricesIncludeTax != nil {
+			sets = append(sets, fmt.Sprintf("prices_include_tax=$%d", idx))
+			args = append(args, effectiveInclTax)
+			idx++
+		}
+
+		if in.Discount != nil {
+			sets = append(sets, fmt.Sprintf("discount=$%d", idx))
+			args = append(args, effectiveDiscount)
+			idx++
+		}
+
 		if needsRecalc {
-			sets = append(sets, fmt.Sprintf("subtotal=$%d", idx))
-			args = append(args, subtotalStr)
+			for _, col := range []struct {
+				name  string
+				value money.Decimal
+			}{
+				{"gross", calc.Gross},
+				{"discount_amount", calc.Discount},
+				{"net", calc.Net},
+				{"cost", calc.Cost},
+				{"subtotal", calc.Net},
+				{"total", calc.Total},
+			} {
+				sets = append(sets, fmt.Sprintf("%s=$%d", col.name, idx))
+				args = append(args, col.value)
+				idx++
+			}
+
+			sets = append(sets, fmt.Sprintf("cost_complete=$%d", idx))
+			args = append(args, calc.CostComplete)
+			idx++
+
+			sets = append(sets, fmt.Sprintf("sections=$%d", idx))
+			args = append(args, calc.Sections)
+			idx++
+
+			sets = append(sets, fmt.Sprintf("measurements=$%d", idx))
+			args = append(args, calc.Measurements)
+			idx++
+
+			sets = append(sets, fmt.Sprintf("labor_lines=$%d", idx))
+			args = append(args, calc.LaborLines)
+			idx++
+
+			sets = append(sets, fmt.Sprintf("tax_ids=$%d", idx))
+			args = append(args, calc.TaxIDs)
 			idx++
 
-			sets = append(sets, fmt.Sprintf("total=$%d", idx))
-			args = append(args, totalStr)
+			sets = append(sets, fmt.Sprintf("taxes=$%d", idx))
+			args = append(args, calc.Taxes)
 			idx++
+
+			sets = append(sets, fmt.Sprintf("rounding_mode=$%d", idx))
+			args = append(args, roundingMode)
+			idx++
+		}
+
+		// Any change besides status produces a new revision.
+		if contentChanged {
+			sets = append(sets, "revision=revision+1")
 		}
 
 		sets = append(sets, "updated_at=now()")
 
-		args = append(args, id)
+		args = append(args, id, ownerID)
 
-		query := fmt.Sprintf(`UPDATE quotes SET %s WHERE id=$%d RETURNING id, client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-                               subtotal, total, currency, notes, public_id, status, created_at, updated_at`, strings.Join(sets, ", "), idx)
+		query := fmt.Sprintf(`UPDATE quotes SET %s WHERE id=$%d AND owner_id=$%d RETURNING `+quoteColumns, strings.Join(sets, ", "), idx, idx+1)
 
 		var q Quote
-		if err := pool.QueryRow(r.Context(), query, args...).Scan(
-			&q.ID, &q.ClientID, &q.Items, &q.LaborHours, &q.LaborRate, &q.MarginPct, &q.TaxPct,
-			&q.Subtotal, &q.Total, &q.Currency, &q.Notes, &q.PublicID, &q.Status, &q.CreatedAt, &q.UpdatedAt,
-		); err != nil {
+		if err := scanQuote(tx.QueryRow(r.Context(), query, args...), &q); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if contentChanged {
+			if err := insertRevision(r.Context(), tx, q, &ownerID); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+		}
+
+		if statusUpdated {
+			if err := recordStatusEvent(r.Context(), tx, id, status, effectiveStatus, actorUser, &ownerID); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
 			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 			return
 		}
@@ -587,6 +933,11 @@ func PatchQuote(pool *pgxpool.Pool) http.HandlerFunc {
 
 func SendQuote(pool *pgxpool.Pool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
 		idStr := strings.TrimSpace(chi.URLParam(r, "id"))
 
 		id, err := uuid.Parse(idStr)
@@ -595,8 +946,18 @@ func SendQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
-		var currentStatus string
-		err = pool.QueryRow(r.Context(), `SELECT status FROM quotes WHERE id=$1`, id).Scan(&currentStatus)
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		var (
+			currentStatus string
+			validUntil    *time.Time
+		)
+		err = tx.QueryRow(r.Context(), `SELECT status, valid_until FROM quotes WHERE id=$1 AND owner_id=$2 FOR UPDATE`, id, ownerID).Scan(&currentStatus, &validUntil)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
@@ -606,41 +967,56 @@ func SendQuote(pool *pgxpool.Pool) http.HandlerFunc {
 			return
 		}
 
-		if currentStatus == "sent" || currentStatus == "accepted" {
-			utils.WriteErr(w, http.StatusConflict, "conflict", "quote already sent")
+		if err := checkTransition(currentStatus, StatusSent, actionSend); err != nil {
+			writeTransitionErr(w, currentStatus, StatusSent)
 			return
 		}
 
+		if isExpired(validUntil) {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "valid_until is in the past; update it before sending")
+			return
+		}
+
+		settings, err := accounts.Get(r.Context(), pool, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defaultValidUntil := today().AddDate(0, 0, settings.DefaultValidDays)
+
+		publicID, err := newPublicID()
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "token_error", err.Error())
+			return
+		}
+
+		// The share link is minted on first send and kept afterwards so links
+		// already handed to the customer keep working.
 		var q Quote
-		err = pool.QueryRow(r.Context(), `
+		err = scanQuote(tx.QueryRow(r.Context(), `
                         UPDATE quotes
-                        SET status='sent', updated_at=now()
-                        WHERE id=$1
-                        RETURNING id, client_id, items, labor_hours, labor_rate, margin_pct, tax_pct,
-                                  subtotal, total, currency, notes, public_id, status, created_at, updated_at
-                `, id).Scan(
-			&q.ID,
-			&q.ClientID,
-			&q.Items,
-			&q.LaborHours,
-			&q.LaborRate,
-			&q.MarginPct,
-			&q.TaxPct,
-			&q.Subtotal,
-			&q.Total,
-			&q.Currency,
-			&q.Notes,
-			&q.PublicID,
-			&q.Status,
-			&q.CreatedAt,
-			&q.UpdatedAt,
-		)
+                        SET status='sent', public_id=COALESCE(public_id, $3),
+                            valid_until=COALESCE(valid_until, $4),
+                            responded_at=NULL, response_ip=NULL, signature_name=NULL,
+                            updated_at=now()
+                        WHERE id=$1 AND owner_id=$2
+                        RETURNING `+quoteColumns, id, ownerID, publicID, defaultValidUntil), &q)
 
 		if err != nil {
 			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
 			return
 		}
 
+		if err := recordStatusEvent(r.Context(), tx, id, currentStatus, StatusSent, actorUser, &ownerID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
 		log.Printf("Quote %s sent to client", id.String())
 
 		utils.WriteJSON(w, http.StatusOK, q)
@@ -657,140 +1033,263 @@ func parseTime(v string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("invalid time")
 }
 
-// calcTotals - Calculates quote totals with precise decimal arithmetic for financial accuracy
-// Purpose: Computes line totals, subtotals, taxes, and final totals using arbitrary precision math
+// calcContext carries what calcTotals needs beyond the quote itself.
+type calcContext struct {
+	Mode    money.RoundingMode
+	Taxes   map[uuid.UUID]taxes.Rate   // definitions of every tax the quote references
+	Catalog map[uuid.UUID]catalog.Item // catalog items the quote's lines reference
+	// LaborRates are the account's default rates for labor lines without one.
+	LaborRates map[string]money.Decimal
+}
+
+// breakdown is the result of calcTotals, rounded to the currency's minor
+// units. Gross - Discount = Net, which is stored as the quote's subtotal,
+// and Net + the tax amounts = Total. With tax-inclusive prices Gross and
+// Discount include tax, so Gross - Discount = Net + the additive taxes.
+//
+// Labor and Margin are informational: all labor before margin, and the margin
+// added on top of the discounted lines and labor. Cost is what the selected
+// lines and the labor cost the seller; CostComplete is false when a selected
+// line has no unit_cost.
+type breakdown struct {
+	Items        []QuoteItem // selected set on optional items and alternatives, formula quantities evaluated
+	Measurements map[string]Measurement
+	Sections     []SectionTotal // empty unless items are grouped in named sections
+	LaborLines   []LaborLine    // with rates resolved and totals filled in
+	Labor        money.Decimal
+	Margin       money.Decimal
+	Gross        money.Decimal
+	Discount     money.Decimal
+	Net          money.Decimal
+	Cost         money.Decimal // Σ qty × unit_cost + labor
+	CostComplete bool
+	TaxIDs       []uuid.UUID
+	Taxes        []TaxLine
+	Total        money.Decimal
+}
+
+// calcTotals - Calculates quote totals with exact decimal arithmetic for financial accuracy
+// Purpose: Computes line totals, discounts, subtotals, taxes, and final totals without binary floating point
 // Advantages:
-//   - Uses big.Rat for precise decimal calculations (no floating-point errors)
-//   - Validates individual items during calculation
-//   - Handles complex business logic (labor, margin, tax calculations)
-//   - Returns formatted currency strings ready for display
+//   - Uses money.Decimal end to end, from JSON input to NUMERIC storage
+//   - Validates individual items and discounts during calculation
+//   - Handles complex business logic (labor, margin, discount, per-line tax calculations)
+//   - Rounds once per figure, to the currency's minor units with the account's rounding mode
+//   - Optional items and unselected alternatives are priced but kept out of every total
+//   - Quantities can be formulas over the quote's measurements, with unit conversion
+//   - Tax-inclusive prices are back-calculated exactly; the rounding residue lands in the
+//     subtotal, so subtotal + taxes always equals what the customer was quoted
 //
 // Weaknesses:
-//   - Performance overhead compared to float64 calculations
 //   - Complex calculation logic mixed with validation
-//   - No support for different rounding methods per currency
+//   - Fixed calculation order (line discounts, margin, quote discount, then tax)
 //   - Error messages could be more user-friendly
-func calcTotals(in CreateQuoteIn) ([]QuoteItem, string, string, error) {
-	sum := big.NewRat(0, 1)
-
-	// Item processing and validation loop
-	// Advantages: Validates each item, calculates precise line totals
-	// Weaknesses: Mixed validation and calculation logic, ignores strconv errors
-	items := make([]QuoteItem, len(in.Items))
-	for i, it := range in.Items {
+func calcTotals(in CreateQuoteIn, cc calcContext) (breakdown, error) {
+	mode := cc.Mode
+	cur, ok := money.LookupCurrency(in.Currency)
+	if !ok {
+		return breakdown{}, fmt.Errorf("currency must be a valid ISO 4217 code")
+	}
+
+	taxDefs := withStoredTaxes(cc.Taxes, in.storedTaxes)
+	quoteTaxIDs, err := resolveTaxIDs(in.TaxIDs, taxDefs)
+	if err != nil {
+		return breakdown{}, fmt.Errorf("tax_ids: %w", err)
+	}
+
+	grossSum, netSum, cost := money.Zero, money.Zero, money.Zero
+	costComplete := true
+	taxed := []taxedLine{}
+
+	measurements, err := resolveMeasurements(in.Measurements)
+	if err != nil {
+		return breakdown{}, err
+	}
+
+	src := trimGrouping(in.Items)
+	included, err := selection(src)
+	if err != nil {
+		return breakdown{}, err
+	}
+
+	items := make([]QuoteItem, len(src))
+	nets := make([]money.Decimal, len(src))
+	for i, it := range src {
+		it, err := fromCatalog(it, cc.Catalog)
+		if err != nil {
+			return breakdown{}, fmt.Errorf("items[%d].catalog_item_id: %w", i, err)
+		}
 		if strings.TrimSpace(it.Name) == "" {
-			return nil, "", "", fmt.Errorf("items[%d].name is required", i)
+			return breakdown{}, fmt.Errorf("items[%d].name is required", i)
 		}
-		if it.Qty < 0 || it.UnitPrice < 0 {
-			return nil, "", "", fmt.Errorf("items[%d] qty/unit_price must be >= 0", i)
+		if it.UnitPrice == nil {
+			p := it.price()
+			it.UnitPrice = &p
+		}
+		if unit, err := units.Normalize(it.Unit); err == nil {
+			it.Unit = unit
+		} else if in.storedUnits[strings.TrimSpace(it.Unit)] || strings.TrimSpace(src[i].Unit) == "" {
+			// Units saved before the registry, on the quote or on the
+			// catalog item the line was filled from, are kept as entered.
+			it.Unit = strings.TrimSpace(it.Unit)
+		} else {
+			return breakdown{}, fmt.Errorf("items[%d].unit: %w", i, err)
+		}
+		if it.QtyFormula = strings.TrimSpace(it.QtyFormula); it.QtyFormula != "" {
+			if it.Qty, err = formulaQty(it.QtyFormula, it.Unit, measurements); err != nil {
+				return breakdown{}, fmt.Errorf("items[%d].qty_formula: %w", i, err)
+			}
+		}
+		if it.Qty.Sign() < 0 || it.UnitPrice.Sign() < 0 {
+			return breakdown{}, fmt.Errorf("items[%d] qty/unit_price must be >= 0", i)
+		}
+		if it.UnitCost != nil && it.UnitCost.Sign() < 0 {
+			return breakdown{}, fmt.Errorf("items[%d].unit_cost must be >= 0", i)
+		}
+		gross := it.Qty.Mul(*it.UnitPrice) // qty * unit_price (exact)
+		net := gross
+		if it.Discount != nil {
+			off, err := it.Discount.off(gross)
+			if err != nil {
+				return breakdown{}, fmt.Errorf("items[%d].discount: %w", i, err)
+			}
+			net = gross.Sub(off)
+		}
+		lineTaxIDs := quoteTaxIDs
+		if it.TaxIDs != nil {
+			ids, err := resolveTaxIDs(*it.TaxIDs, taxDefs)
+			if err != nil {
+				return breakdown{}, fmt.Errorf("items[%d].tax_ids: %w", i, err)
+			}
+			it.TaxIDs = &ids
+			lineTaxIDs = ids
+		}
+		rounded := cur.Round(net, mode)
+		it.LineTotal = &rounded
+		it.Selected = nil
+		if it.isOption() {
+			sel := included[i]
+			it.Selected = &sel
 		}
-		lt := mulRat(dec(it.Qty), dec(it.UnitPrice)) // qty * unit_price (precise)
-		lt2 := round2(lt)                            // Round to 2 decimals
-		ltF, _ := strconv.ParseFloat(lt2, 64)        // Convert back to float64 (error ignored)
-		it.LineTotal = &ltF
 		items[i] = it
-		sum = sum.Add(sum, lt) // Accumulate precise sum
+		nets[i] = net
+
+		if !included[i] {
+			// Shown with its price, but not part of the selected configuration.
+			// Its taxes are listed with a zero base, so the quote keeps the
+			// definition of every tax a customer's choice can bring in.
+			taxed = append(taxed, taxedLine{amount: money.Zero, taxIDs: lineTaxIDs})
+			continue
+		}
+		if it.UnitCost != nil {
+			cost = cost.Add(it.Qty.Mul(*it.UnitCost))
+		} else {
+			costComplete = false
+		}
+		taxed = append(taxed, taxedLine{amount: net, taxIDs: lineTaxIDs})
+		grossSum = grossSum.Add(gross) // Accumulate exact sums
+		netSum = netSum.Add(net)
+	}
+
+	if in.LaborHours.Sign() < 0 || in.LaborRate.Sign() < 0 {
+		return breakdown{}, fmt.Errorf("labor_hours and labor_rate must be >= 0")
+	}
+	laborLines, lineLabor, err := resolveLabor(in.LaborLines, cc.LaborRates, cur, mode)
+	if err != nil {
+		return breakdown{}, err
 	}
 
 	// Financial calculations with proper business logic flow
-	// Advantages: Clear calculation sequence, precise arithmetic, follows standard quote calculation
-	// Weaknesses: Fixed calculation order (margin before tax), no support for tax-inclusive pricing
-	labor := mulRat(dec(in.LaborHours), dec(in.LaborRate)) // Labor cost calculation
-	base := new(big.Rat).Add(sum, labor)                   // Items + Labor = Base
-	margin := mulRat(base, pctToRat(in.MarginPct))         // Margin calculation
-	subtotal := new(big.Rat).Add(base, margin)             // Base + Margin = Subtotal
-	tax := mulRat(subtotal, pctToRat(in.TaxPct))           // Tax on subtotal
-	total := new(big.Rat).Add(subtotal, tax)               // Final total
-
-	return items, round2(subtotal), round2(total), nil
+	// Advantages: Clear calculation sequence, exact arithmetic, follows standard quote calculation
+	// Weaknesses: Fixed order; margin and discounts apply to the entered (possibly tax-inclusive) prices
+	labor := in.LaborHours.Mul(in.LaborRate).Add(lineLabor) // Labor cost calculation
+	markup := hundred.Add(in.MarginPct)                     // 100 + margin, as a percentage
+	gross := grossSum.Add(labor).Percent(markup)            // Before any discount
+	undiscounted := netSum.Add(labor).Percent(markup)       // After line discounts
+	net := undiscounted
+	if in.Discount != nil {
+		off, err := in.Discount.off(net)
+		if err != nil {
+			return breakdown{}, fmt.Errorf("discount: %w", err)
+		}
+		net = net.Sub(off) // Quote discount, before tax
+	}
+
+	if !labor.IsZero() {
+		taxed = append(taxed, taxedLine{amount: labor, taxIDs: quoteTaxIDs})
+	}
+
+	// Each line's taxable base is its amount with margin and its share of
+	// the quote discount applied.
+	factor := new(big.Rat).Quo(markup.Rat(), big.NewRat(100, 1))
+	if undiscounted.Sign() > 0 {
+		factor.Mul(factor, new(big.Rat).Quo(net.Rat(), undiscounted.Rat()))
+	}
+
+	b := breakdown{
+		Items:        items,
+		Measurements: measurements,
+		Sections:     sectionTotals(items, included, nets, cur, mode),
+		LaborLines:   laborLines,
+		Labor:        cur.Round(labor, mode),
+		Margin:       cur.Round(undiscounted.Sub(netSum.Add(labor)), mode),
+		Gross:        cur.Round(gross, mode),
+		Net:          cur.Round(net, mode),
+		Cost:         cur.Round(cost.Add(labor), mode), // margin marks labor up like a cost
+		CostComplete: costComplete,
+		TaxIDs:       quoteTaxIDs,
+		Taxes:        applyTaxes(taxed, factor, in.TaxPct, taxDefs, in.PricesIncludeTax, cur, mode),
+	}
+	b.Discount = b.Gross.Sub(b.Net) // So gross - discount = net holds after rounding
+
+	if in.PricesIncludeTax {
+		// net is what the customer pays before withholdings; the subtotal is
+		// what remains once the rounded additive taxes are taken out of it.
+		payable := b.Net
+		b.Total = payable
+		for _, t := range b.Taxes {
+			if t.Withholding {
+				b.Total = b.Total.Add(t.Amount)
+			} else {
+				b.Net = b.Net.Sub(t.Amount)
+			}
+		}
+	} else {
+		b.Total = b.Net // Net plus every (rounded) tax line, so the breakdown adds up
+		for _, t := range b.Taxes {
+			b.Total = b.Total.Add(t.Amount)
+		}
+	}
+	if b.Total.Sign() < 0 {
+		return breakdown{}, fmt.Errorf("tax_ids: withholdings exceed the quote total")
+	}
+	return b, nil
 }
 
-// dec - Converts float64 to precise big.Rat representation for financial calculations
-// Purpose: Eliminates floating-point precision errors by converting to exact rational numbers
-// Advantages:
-//   - Prevents IEEE 754 floating-point precision issues in financial calculations
-//   - Maintains exact decimal representation (12.34 -> 1234/100)
-//   - Consistent 6-decimal precision for input processing
-//   - Essential for accurate money calculations
-//
-// Weaknesses:
-//   - Performance overhead compared to native float64 operations
-//   - Fixed 6-decimal precision may be insufficient for some currencies
-//   - No input validation for extreme or invalid values
-//   - Memory allocation on each conversion
-func dec(f float64) *big.Rat {
-	// Convert 12.34 -> 1234/100 to avoid imprecise binary representation
-	s := fmt.Sprintf("%.6f", f) // Sufficient precision for our financial inputs
-	r := new(big.Rat)
-	r.SetString(s)
-	return r
+var hundred = money.NewFromInt(100)
+
+// quoteCurrency returns the currency of a stored quote. Quotes saved before
+// currencies were validated fall back to two minor units.
+func quoteCurrency(q Quote) money.Currency {
+	if cur, ok := money.LookupCurrency(q.Currency); ok {
+		return cur
+	}
+	return money.Currency{Code: q.Currency, MinorUnits: 2}
 }
 
-// mulRat - Multiplies two big.Rat numbers and returns new result (immutable operation)
-// Purpose: Performs exact multiplication on arbitrary precision rational numbers
-// Advantages:
-//   - No precision loss in multiplication operations
-//   - Immutable operation (doesn't modify input parameters)
-//   - Simple one-liner for complex big.Rat arithmetic
-//   - Thread-safe (no shared state modification)
-//
-// Weaknesses:
-//   - Allocates new big.Rat on each call (memory overhead)
-//   - No input validation (nil pointer handling)
-//   - Limited to multiplication only (no division, addition, etc.)
-//   - Could be inlined for performance
-func mulRat(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) }
-
-// round2 - Rounds big.Rat to 2 decimal places using half-up rounding for currency display
-// Purpose: Provides consistent currency formatting with banker's rounding for financial applications
-// Advantages:
-//   - Uses "half-up" rounding (0.125 -> 0.13) standard for financial calculations
-//   - Always returns exactly 2 decimal places for consistent currency display
-//   - Handles edge cases (small numbers, single/double digits correctly)
-//   - No floating-point precision issues due to big.Rat usage
-//
-// Weaknesses:
-//   - Complex string manipulation logic (error-prone)
-//   - Fixed 2-decimal formatting (not configurable for different currencies)
-//   - No input validation or error handling for edge cases
-//   - Performance overhead for string operations
-func round2(r *big.Rat) string {
-	// Multiply by 100 to shift decimal places for rounding
-	x100 := new(big.Rat).Mul(r, big.NewRat(100, 1))
-	// Half-up rounding: add 0.5 and truncate (floor operation)
-	plus := new(big.Rat).Add(x100, big.NewRat(1, 2))
-	i := new(big.Int)
-	plus.FloatString(10) // Ensure internal fraction reduction
-	i.Div(plus.Num(), plus.Denom())
-	// Convert back to 2 decimal places with proper formatting
-	s := i.String()
-	// Insert decimal point at appropriate position for currency display
-	if len(s) == 1 {
-		return "0.0" + s
-	}
-	if len(s) == 2 {
-		return "0." + s
-	}
-	return s[:len(s)-2] + "." + s[len(s)-2:]
+// profitOf returns subtotal - cost and that profit as a percentage of the
+// subtotal, matching the gross_profit and effective_margin_pct columns. The
+// percentage is nil when the cost is partial.
+func profitOf(subtotal, cost money.Decimal, complete bool) (money.Decimal, *money.Decimal) {
+	profit := subtotal.Sub(cost)
+	if !complete || subtotal.Sign() <= 0 {
+		return profit, nil
+	}
+	pct := money.FromRat(new(big.Rat).Quo(profit.Mul(hundred).Rat(), subtotal.Rat()), 2, money.RoundHalfUp)
+	return profit, &pct
 }
 
-// pctToRat - Converts percentage (0-100) to decimal ratio (0.0-1.0) using big.Rat
-// Purpose: Safely converts percentage values to decimal ratios for precise calculations
-// Advantages:
-//   - Maintains precision by using big.Rat arithmetic
-//   - Handles percentage to decimal conversion accurately (50.5% -> 0.505)
-//   - No floating-point precision loss in division operation
-//   - Essential for accurate margin and tax calculations
-//
-// Weaknesses:
-//   - No input validation (negative percentages, values > 100)
-//   - Performance overhead compared to simple float64 division
-//   - Allocates multiple big.Rat objects for simple operation
-//   - Could benefit from input bounds checking
-//
-// Example: pctToRat(25.5) returns big.Rat representing 0.255
-func pctToRat(p float64) *big.Rat {
-	r := new(big.Rat)
-	r.SetFloat64(p)
-	return new(big.Rat).Quo(r, big.NewRat(100, 1)) // p / 100 (precise division)
+// pctInRange reports whether p is a percentage between 0 and 100.
+func pctInRange(p money.Decimal) bool {
+	return p.Sign() >= 0 && p.Cmp(hundred) <= 0
 }
@@ -0,0 +1,87 @@
+package quotes
+
+import (
+	"testing"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+func TestCalcTotalsCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       CreateQuoteIn
+		cost     string
+		complete bool
+		pct      string // "" for null
+	}{
+		{
+			name: "items and labor",
+			in: CreateQuoteIn{
+				Items:      []QuoteItem{{Name: "Pipe", Qty: money.MustParse("2"), UnitPrice: price("50"), UnitCost: price("30")}},
+				LaborHours: money.MustParse("1"),
+				LaborRate:  money.MustParse("100"),
+				MarginPct:  money.MustParse("20"),
+			},
+			// subtotal (100 + 100) × 1.2 = 240
+			cost: "160.00", complete: true, pct: "33.33",
+		},
+		{
+			name: "labor only",
+			in: CreateQuoteIn{
+				Items:      []QuoteItem{{Name: "Visit", Qty: money.MustParse("1"), UnitPrice: price("0"), UnitCost: price("0")}},
+				LaborLines: []LaborLine{{Role: "Electrician", Hours: money.MustParse("4"), Rate: price("25")}},
+				MarginPct:  money.MustParse("20"),
+			},
+			cost: "100.00", complete: true, pct: "16.67",
+		},
+		{
+			name: "item without unit_cost",
+			in: CreateQuoteIn{
+				Items: []QuoteItem{
+					{Name: "Pipe", Qty: money.MustParse("1"), UnitPrice: price("100"), UnitCost: price("60")},
+					{Name: "Fittings", Qty: money.MustParse("1"), UnitPrice: price("50")},
+				},
+			},
+			cost: "60.00", complete: false, pct: "",
+		},
+		{
+			name: "unselected option without unit_cost",
+			in: CreateQuoteIn{
+				Items: []QuoteItem{
+					{Name: "Pipe", Qty: money.MustParse("1"), UnitPrice: price("100"), UnitCost: price("60")},
+					{Name: "Extra valve", Qty: money.MustParse("1"), UnitPrice: price("40"), Optional: true},
+					{Name: "Copper", Qty: money.MustParse("1"), UnitPrice: price("80"), UnitCost: price("50"), Group: "material"},
+					{Name: "PEX", Qty: money.MustParse("1"), UnitPrice: price("60"), Group: "material"},
+				},
+			},
+			// subtotal 180, cost 110
+			cost: "110.00", complete: true, pct: "38.89",
+		},
+		{
+			name: "empty subtotal",
+			in: CreateQuoteIn{
+				Items: []QuoteItem{{Name: "Sample", Qty: money.MustParse("1"), UnitPrice: price("0"), UnitCost: price("5")}},
+			},
+			cost: "5.00", complete: true, pct: "",
+		},
+	}
+	for _, tt := range tests {
+		tt.in.Currency = "USD"
+		b, err := calcTotals(tt.in, calcContext{Mode: money.RoundHalfUp})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b.Cost.String() != tt.cost || b.CostComplete != tt.complete {
+			t.Errorf("%s: cost = %s complete = %v, want %s %v", tt.name, b.Cost, b.CostComplete, tt.cost, tt.complete)
+		}
+
+		_, pct := profitOf(b.Net, b.Cost, b.CostComplete)
+		switch {
+		case tt.pct == "" && pct != nil:
+			t.Errorf("%s: effective margin = %s, want null", tt.name, pct)
+		case tt.pct != "" && (pct == nil || pct.String() != tt.pct):
+			t.Errorf("%s: effective margin = %v, want %s", tt.name, pct, tt.pct)
+		}
+	}
+}
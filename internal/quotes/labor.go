@@ -0,0 +1,74 @@
+package quotes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+// LaborLine is labor for one role. Rate defaults to the account's rate for
+// the role and is stored resolved, so later changes to the defaults do not
+// alter the quote. Overtime multiplies the rate (1.5 for time and a half).
+type LaborLine struct {
+	Role     string         `json:"role"`
+	Hours    money.Decimal  `json:"hours"`
+	Rate     *money.Decimal `json:"rate"`
+	Overtime *money.Decimal `json:"overtime,omitempty"`
+	Total    *money.Decimal `json:"total,omitempty"` // hours × rate × overtime
+}
+
+// amount returns the exact cost of a resolved line.
+func (l LaborLine) amount() money.Decimal {
+	a := l.Hours.Mul(*l.Rate)
+	if l.Overtime != nil {
+		a = a.Mul(*l.Overtime)
+	}
+	return a
+}
+
+// resolveLabor validates labor lines and fills in default rates and totals.
+// It returns the resolved lines and their exact sum.
+func resolveLabor(lines []LaborLine, rates map[string]money.Decimal, cur money.Currency, mode money.RoundingMode) ([]LaborLine, money.Decimal, error) {
+	out := make([]LaborLine, len(lines))
+	sum := money.Zero
+	for i, l := range lines {
+		l.Role = strings.TrimSpace(l.Role)
+		if l.Role == "" {
+			return nil, money.Zero, fmt.Errorf("labor_lines[%d].role is required", i)
+		}
+		if l.Hours.Sign() < 0 {
+			return nil, money.Zero, fmt.Errorf("labor_lines[%d].hours must be >= 0", i)
+		}
+		if l.Rate == nil {
+			rate, ok := roleRate(rates, l.Role)
+			if !ok {
+				return nil, money.Zero, fmt.Errorf("labor_lines[%d].rate is required: no default rate for role %q", i, l.Role)
+			}
+			l.Rate = &rate
+		}
+		if l.Rate.Sign() < 0 {
+			return nil, money.Zero, fmt.Errorf("labor_lines[%d].rate must be >= 0", i)
+		}
+		if l.Overtime != nil && l.Overtime.Cmp(money.NewFromInt(1)) < 0 {
+			return nil, money.Zero, fmt.Errorf("labor_lines[%d].overtime must be >= 1", i)
+		}
+
+		amt := l.amount()
+		rounded := cur.Round(amt, mode)
+		l.Total = &rounded
+		out[i] = l
+		sum = sum.Add(amt)
+	}
+	return out, sum, nil
+}
+
+// roleRate looks up the default rate of a role, ignoring case.
+func roleRate(rates map[string]money.Decimal, role string) (money.Decimal, bool) {
+	for name, rate := range rates {
+		if strings.EqualFold(name, role) {
+			return rate, true
+		}
+	}
+	return money.Zero, false
+}
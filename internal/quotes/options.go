@@ -0,0 +1,266 @@
+package quotes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/taxes"
+)
+
+// SectionTotal is the subtotal of a named group of items: the sum of the
+// line totals of its selected items, before margin and quote discount.
+type SectionTotal struct {
+	Name     string        `json:"name"`
+	Subtotal money.Decimal `json:"subtotal"`
+}
+
+// selection decides which items count toward the totals. Plain items always
+// do; an optional item only when selected; and of each alternative group
+// exactly one item, the selected one or else the first of the group.
+func selection(items []QuoteItem) ([]bool, error) {
+	included := make([]bool, len(items))
+	groups := map[string][]int{}
+	order := []string{}
+
+	for i, it := range items {
+		switch {
+		case it.Optional && it.Group != "":
+			return nil, fmt.Errorf("items[%d]: an item cannot be both optional and an alternative", i)
+		case it.Group != "":
+			if _, ok := groups[it.Group]; !ok {
+				order = append(order, it.Group)
+			}
+			groups[it.Group] = append(groups[it.Group], i)
+		case it.Optional:
+			included[i] = it.Selected != nil && *it.Selected
+		default:
+			included[i] = true
+		}
+	}
+
+	for _, g := range order {
+		chosen := -1
+		for _, i := range groups[g] {
+			if items[i].Selected != nil && *items[i].Selected {
+				if chosen >= 0 {
+					return nil, fmt.Errorf("items[%d]: group %q already has a selected alternative", i, g)
+				}
+				chosen = i
+			}
+		}
+		if chosen < 0 {
+			chosen = groups[g][0]
+		}
+		included[chosen] = true
+	}
+	return included, nil
+}
+
+// isOption reports whether the customer can choose the item.
+func (it QuoteItem) isOption() bool {
+	return it.Optional || it.Group != ""
+}
+
+// hasOptions reports whether any item is optional or an alternative.
+func hasOptions(items []QuoteItem) bool {
+	for _, it := range items {
+		if it.isOption() {
+			return true
+		}
+	}
+	return false
+}
+
+// choose applies the customer's choice to the items: options are the
+// indexes of the optional items and alternatives they want. Optional items
+// not listed are dropped; groups with no listed alternative keep the
+// seller's selection.
+func choose(items []QuoteItem, options []int) ([]QuoteItem, error) {
+	out := make([]QuoteItem, len(items))
+	copy(out, items)
+
+	chosen := map[int]bool{}
+	picked := map[string]int{}
+	for _, i := range options {
+		if i < 0 || i >= len(out) || !out[i].isOption() {
+			return nil, fmt.Errorf("options: item %d is not optional or an alternative", i)
+		}
+		if g := out[i].Group; g != "" {
+			if j, dup := picked[g]; dup && j != i {
+				return nil, fmt.Errorf("options: only one alternative of group %q can be chosen", g)
+			}
+			picked[g] = i
+		}
+		chosen[i] = true
+	}
+
+	for i, it := range out {
+		if !it.isOption() {
+			continue
+		}
+		if _, ok := picked[it.Group]; it.Group != "" && !ok {
+			continue
+		}
+		sel := chosen[i]
+		out[i].Selected = &sel
+	}
+	return out, nil
+}
+
+// chosenOptions returns the indexes of the selected optional items and
+// alternatives of computed items.
+func chosenOptions(items []QuoteItem) []int {
+	out := []int{}
+	for i, it := range items {
+		if it.isOption() && it.Selected != nil && *it.Selected {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// sectionTotals sums line totals per section, in order of first appearance.
+// A quote without named sections has none.
+func sectionTotals(items []QuoteItem, included []bool, nets []money.Decimal, cur money.Currency, mode money.RoundingMode) []SectionTotal {
+	named := false
+	for _, it := range items {
+		if it.Section != "" {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return []SectionTotal{}
+	}
+
+	order := []string{}
+	sums := map[string]money.Decimal{}
+	for i, it := range items {
+		if _, ok := sums[it.Section]; !ok {
+			order = append(order, it.Section)
+			sums[it.Section] = money.Zero
+		}
+		if included[i] {
+			sums[it.Section] = sums[it.Section].Add(nets[i])
+		}
+	}
+
+	out := make([]SectionTotal, len(order))
+	for i, name := range order {
+		out[i] = SectionTotal{Name: name, Subtotal: cur.Round(sums[name], mode)}
+	}
+	return out
+}
+
+// trimGrouping normalizes section and group names.
+func trimGrouping(items []QuoteItem) []QuoteItem {
+	out := make([]QuoteItem, len(items))
+	for i, it := range items {
+		it.Section = strings.TrimSpace(it.Section)
+		it.Group = strings.TrimSpace(it.Group)
+		out[i] = it
+	}
+	return out
+}
+
+// choiceError is an invalid choice of options by the customer.
+type choiceError struct{ err error }
+
+func (e choiceError) Error() string { return e.err.Error() }
+
+// acceptOptions records the options of a quote being accepted. When the
+// customer chose options the quote is recalculated for them, with the tax
+// definitions it was sent with, and stored as a new revision. Nothing the
+// seller changed since sending can make a valid choice fail.
+func acceptOptions(ctx context.Context, tx pgx.Tx, q Quote, options *[]int) (Quote, error) {
+	in, err := quoteInOf(q)
+	if err != nil {
+		return q, err
+	}
+
+	if !hasOptions(in.Items) {
+		if options != nil && len(*options) > 0 {
+			return q, choiceError{fmt.Errorf("options: this quote has no optional items or alternatives")}
+		}
+		return q, nil
+	}
+
+	if options == nil {
+		err = scanQuote(tx.QueryRow(ctx, `
+			UPDATE quotes SET chosen_options=$2 WHERE id=$1 RETURNING `+quoteColumns,
+			q.ID, chosenOptions(in.Items)), &q)
+		return q, err
+	}
+
+	calc, err := recalcChoice(q, in, *options)
+	if err != nil {
+		return q, err
+	}
+
+	itemsJSON, err := json.Marshal(calc.Items)
+	if err != nil {
+		return q, err
+	}
+
+	err = scanQuote(tx.QueryRow(ctx, `
+		UPDATE quotes SET
+			items=$2, sections=$3, gross=$4, discount_amount=$5, net=$6, cost=$7, cost_complete=$11, subtotal=$6,
+			taxes=$8, total=$9, chosen_options=$10, revision=revision+1, updated_at=now()
+		WHERE id=$1
+		RETURNING `+quoteColumns,
+		q.ID, itemsJSON, calc.Sections, calc.Gross, calc.Discount, calc.Net, calc.Cost,
+		calc.Taxes, calc.Total, chosenOptions(calc.Items), calc.CostComplete), &q)
+	if err != nil {
+		return q, err
+	}
+
+	return q, insertRevision(ctx, tx, q, nil)
+}
+
+// recalcChoice applies the customer's choice of options to in, the content
+// of q, and recalculates it. Every error is a choiceError.
+func recalcChoice(q Quote, in CreateQuoteIn, options []int) (breakdown, error) {
+	items, err := choose(in.Items, options)
+	if err != nil {
+		return breakdown{}, choiceError{err}
+	}
+	in.Items = items
+
+	// The quote's tax lines hold the definition of every tax its lines use,
+	// including those of lines not chosen when it was sent.
+	defs := taxDefsOf(q.Taxes)
+	dropUnknownTaxes(&in, defs)
+
+	calc, err := calcTotals(in, calcContext{Mode: q.RoundingMode, Taxes: defs})
+	if err != nil {
+		return breakdown{}, choiceError{err}
+	}
+	return calc, nil
+}
+
+// dropUnknownTaxes removes from the items the taxes without a definition.
+// Quotes sent before every tax was kept in their tax lines may reference,
+// on a line that was not chosen, a tax the quote has no definition for.
+func dropUnknownTaxes(in *CreateQuoteIn, defs map[uuid.UUID]taxes.Rate) {
+	known := func(ids []uuid.UUID) []uuid.UUID {
+		out := []uuid.UUID{}
+		for _, id := range ids {
+			if _, ok := defs[id]; ok {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+	for i, it := range in.Items {
+		if it.TaxIDs != nil {
+			ids := known(*it.TaxIDs)
+			in.Items[i].TaxIDs = &ids
+		}
+	}
+	in.TaxIDs = known(in.TaxIDs)
+}
@@ -0,0 +1,228 @@
+package quotes
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+func selected(b bool) *bool { return &b }
+
+func TestSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []QuoteItem
+		want  []bool
+		err   string
+	}{
+		{
+			name:  "plain items",
+			items: []QuoteItem{{}, {}},
+			want:  []bool{true, true},
+		},
+		{
+			name:  "optional items",
+			items: []QuoteItem{{Optional: true}, {Optional: true, Selected: selected(true)}, {Optional: true, Selected: selected(false)}},
+			want:  []bool{false, true, false},
+		},
+		{
+			name:  "alternatives default to the first",
+			items: []QuoteItem{{Group: "a"}, {}, {Group: "a"}, {Group: "b"}},
+			want:  []bool{true, true, false, true},
+		},
+		{
+			name:  "selected alternative",
+			items: []QuoteItem{{Group: "a"}, {Group: "a", Selected: selected(true)}, {Group: "a", Selected: selected(false)}},
+			want:  []bool{false, true, false},
+		},
+		{
+			name:  "two selected alternatives",
+			items: []QuoteItem{{Group: "a", Selected: selected(true)}, {Group: "a", Selected: selected(true)}},
+			err:   `items[1]: group "a" already has a selected alternative`,
+		},
+		{
+			name:  "optional alternative",
+			items: []QuoteItem{{Group: "a", Optional: true}},
+			err:   "items[0]: an item cannot be both optional and an alternative",
+		},
+	}
+	for _, tt := range tests {
+		got, err := selection(tt.items)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: selection = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	items := []QuoteItem{
+		{Name: "Base"},
+		{Name: "Valve", Optional: true, Selected: selected(true)},
+		{Name: "Timer", Optional: true},
+		{Name: "Copper", Group: "pipe", Selected: selected(true)},
+		{Name: "PEX", Group: "pipe"},
+		{Name: "White", Group: "color"},
+		{Name: "Black", Group: "color"},
+	}
+
+	tests := []struct {
+		name    string
+		options []int
+		want    []int // chosen options afterwards
+		err     string
+	}{
+		{name: "nothing", options: []int{}, want: []int{3}},
+		{name: "optional items", options: []int{2}, want: []int{2, 3}},
+		{name: "alternative", options: []int{4, 6}, want: []int{4, 6}},
+		{name: "repeated index", options: []int{1, 1, 4}, want: []int{1, 4}},
+		{name: "plain item", options: []int{0}, err: "options: item 0 is not optional or an alternative"},
+		{name: "out of range", options: []int{7}, err: "options: item 7 is not optional or an alternative"},
+		{name: "negative", options: []int{-1}, err: "options: item -1 is not optional or an alternative"},
+		{name: "two alternatives", options: []int{3, 4}, err: `options: only one alternative of group "pipe" can be chosen`},
+	}
+	for _, tt := range tests {
+		got, err := choose(items, tt.options)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if c := chosenOptions(got); !slices.Equal(c, tt.want) {
+			t.Errorf("%s: chosen = %v, want %v", tt.name, c, tt.want)
+		}
+	}
+
+	if *items[1].Selected != true || items[2].Selected != nil {
+		t.Error("choose modified its input")
+	}
+}
+
+// optionsQuote is a quote with a plain line, an optional item and a pair of
+// alternatives, the unselected one carrying a tax of its own.
+func optionsQuote(t *testing.T) breakdown {
+	t.Helper()
+	iva := testTax("IVA", "16", false, false)
+	ieps := testTax("IEPS", "8", false, false)
+
+	in := CreateQuoteIn{
+		Items: []QuoteItem{
+			{Name: "Install", Qty: money.MustParse("1"), UnitPrice: price("100")},
+			{Name: "Timer", Qty: money.MustParse("1"), UnitPrice: price("30"), Optional: true},
+			{Name: "Standard", Qty: money.MustParse("1"), UnitPrice: price("50"), Group: "heater"},
+			{Name: "Premium", Qty: money.MustParse("1"), UnitPrice: price("80"), Group: "heater", TaxIDs: &[]uuid.UUID{iva.ID, ieps.ID}},
+		},
+		TaxIDs:   []uuid.UUID{iva.ID},
+		Currency: "MXN",
+	}
+	b, err := calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap(iva, ieps)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCalcTotalsOptions(t *testing.T) {
+	b := optionsQuote(t)
+
+	if b.Net.String() != "150.00" || b.Total.String() != "174.00" {
+		t.Errorf("net = %s, total = %s; want 150.00 and 174.00", b.Net, b.Total)
+	}
+
+	sel := []string{}
+	for _, it := range b.Items {
+		switch {
+		case it.Selected == nil:
+			sel = append(sel, "-")
+		case *it.Selected:
+			sel = append(sel, "yes")
+		default:
+			sel = append(sel, "no")
+		}
+	}
+	if got := strings.Join(sel, " "); got != "- no yes no" {
+		t.Errorf("selected = %s, want - no yes no", got)
+	}
+	if b.Items[3].LineTotal.String() != "80.00" {
+		t.Errorf("unselected alternative line_total = %s, want 80.00", b.Items[3].LineTotal)
+	}
+
+	// The unselected alternative's tax is kept with a zero base.
+	if len(b.Taxes) != 2 || b.Taxes[1].Name != "IEPS" || !b.Taxes[1].Base.IsZero() || !b.Taxes[1].Amount.IsZero() {
+		t.Errorf("taxes = %+v, want IVA and a zero IEPS line", b.Taxes)
+	}
+}
+
+func TestRecalcChoice(t *testing.T) {
+	b := optionsQuote(t)
+	itemsJSON, err := json.Marshal(b.Items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As stored when sent; the account's taxes are not consulted.
+	q := Quote{
+		Items:        itemsJSON,
+		TaxIDs:       b.TaxIDs,
+		Taxes:        b.Taxes,
+		Currency:     "MXN",
+		RoundingMode: money.RoundHalfUp,
+	}
+	in, err := quoteInOf(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		q       Quote
+		options []int
+		net     string
+		taxes   []string
+		total   string
+	}{
+		{name: "seller's selection", q: q, options: []int{}, net: "150.00", taxes: []string{"IVA 24.00", "IEPS 0.00"}, total: "174.00"},
+		{name: "optional item", q: q, options: []int{1}, net: "180.00", taxes: []string{"IVA 28.80", "IEPS 0.00"}, total: "208.80"},
+		{name: "taxed alternative", q: q, options: []int{3}, net: "180.00", taxes: []string{"IVA 28.80", "IEPS 6.40"}, total: "215.20"},
+		{
+			// Quotes sent before unselected lines kept their taxes.
+			name:    "alternative's tax not snapshotted",
+			q:       Quote{Items: itemsJSON, TaxIDs: b.TaxIDs, Taxes: b.Taxes[:1], Currency: "MXN", RoundingMode: money.RoundHalfUp},
+			options: []int{3},
+			net:     "180.00", taxes: []string{"IVA 28.80"}, total: "208.80",
+		},
+	}
+	for _, tt := range tests {
+		calc, err := recalcChoice(tt.q, in, tt.options)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := []string{}
+		for _, tl := range calc.Taxes {
+			got = append(got, tl.Name+" "+tl.Amount.String())
+		}
+		if calc.Net.String() != tt.net || calc.Total.String() != tt.total || strings.Join(got, ", ") != strings.Join(tt.taxes, ", ") {
+			t.Errorf("%s: net = %s, taxes = %q, total = %s; want %s, %q, %s", tt.name, calc.Net, got, calc.Total, tt.net, tt.taxes, tt.total)
+		}
+	}
+
+	if _, err := recalcChoice(q, in, []int{2, 3}); !errors.As(err, new(choiceError)) {
+		t.Errorf("choosing both alternatives: error = %v, want a choiceError", err)
+	}
+}
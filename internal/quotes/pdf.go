@@ -0,0 +1,509 @@
+package quotes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/accounts"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/clients"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/pdf"
+	"github.com/roblesvargas97/estimago/internal/plans"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// QuotePDF renders a quote as a customer-facing PDF document.
+func QuotePDF(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var q Quote
+		err = scanQuote(pool.QueryRow(r.Context(), `SELECT `+quoteColumns+` FROM quotes WHERE id=$1 AND owner_id=$2`, id, ownerID), &q)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+				return
+			}
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		doc := quoteDocument{Quote: q}
+
+		if q.ClientID != nil {
+			c, err := clients.GetByID(r.Context(), pool, ownerID, *q.ClientID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			if err == nil {
+				doc.Client = &c
+			}
+		}
+
+		if plans.FromRequest(r).Has(plans.FeatureBranding) {
+			settings, err := accounts.Get(r.Context(), pool, ownerID)
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			doc.FooterText = settings.FooterText
+
+			if settings.HasLogo {
+				doc.Logo, err = accounts.GetLogo(r.Context(), pool, ownerID)
+				if err != nil {
+					utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+					return
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := doc.render(&buf); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "render_error", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, quoteNumber(q)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
+
+// customerLine is a row as the customer sees it: margin is folded into the
+// prices instead of being listed. Item is the index of the quote item the
+// row shows, which is what the customer names when choosing options; labor
+// rows have none.
+type customerLine struct {
+	Item      *int          `json:"item,omitempty"`
+	Name      string        `json:"name"`
+	Qty       money.Decimal `json:"qty"`
+	Unit      string        `json:"unit"`
+	UnitPrice money.Decimal `json:"unit_price"`
+	Amount    money.Decimal `json:"amount"`
+	Section   string        `json:"section,omitempty"`
+	Optional  bool          `json:"optional,omitempty"`
+	Group     string        `json:"group,omitempty"`
+	Selected  *bool         `json:"selected,omitempty"`
+}
+
+// excluded reports whether the row is an option left out of the totals.
+func (l customerLine) excluded() bool {
+	return l.Selected != nil && !*l.Selected
+}
+
+// customerLines - Builds margin-hidden rows for customer-facing documents
+// Purpose: Spreads margin_pct over every item and labor row so no margin line is shown
+// Advantages:
+//   - Exact decimal math, rounded once per row
+//   - Rows are shown before discounts, which are listed once as a total
+//   - Rounding residue goes to the last row so rows always add up to the stored gross
+//   - Items are grouped by section; options left out are listed but not summed
+//
+// Weaknesses:
+//   - Displayed unit price × qty can differ by a cent from the row amount
+func customerLines(q Quote) ([]customerLine, error) {
+	var items []QuoteItem
+	if err := json.Unmarshal(q.Items, &items); err != nil {
+		return nil, err
+	}
+
+	factor := hundred.Add(q.MarginPct)
+	cur := quoteCurrency(q)
+
+	lines := []customerLine{}
+	add := func(name string, qty money.Decimal, unit string, unitPrice money.Decimal) *customerLine {
+		lines = append(lines, customerLine{
+			Name:      name,
+			Qty:       qty.Trim(),
+			Unit:      unit,
+			UnitPrice: cur.Round(unitPrice.Percent(factor), q.RoundingMode),
+			Amount:    cur.Round(qty.Mul(unitPrice).Percent(factor), q.RoundingMode),
+		})
+		return &lines[len(lines)-1]
+	}
+
+	// Items of a section are listed together, sections in order of first
+	// appearance.
+	rank := map[string]int{}
+	for _, it := range items {
+		if _, ok := rank[it.Section]; !ok {
+			rank[it.Section] = len(rank)
+		}
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return rank[items[order[a]].Section] < rank[items[order[b]].Section]
+	})
+
+	for _, i := range order {
+		it := items[i]
+		ln := add(it.Name, it.Qty, it.Unit, it.price())
+		ln.Item = &i
+		ln.Section = it.Section
+		ln.Optional = it.Optional
+		ln.Group = it.Group
+		ln.Selected = it.Selected
+	}
+
+	if q.LaborHours.Mul(q.LaborRate).Sign() > 0 {
+		add("Labor", q.LaborHours, "h", q.LaborRate)
+	}
+
+	for _, l := range q.LaborLines {
+		if l.Rate == nil || l.amount().Sign() == 0 {
+			continue
+		}
+		rate := *l.Rate
+		if l.Overtime != nil {
+			rate = rate.Mul(*l.Overtime)
+		}
+		add(l.Role, l.Hours, "h", rate)
+	}
+
+	sum := money.Zero
+	last := -1
+	for i, ln := range lines {
+		if !ln.excluded() {
+			sum = sum.Add(ln.Amount)
+			last = i
+		}
+	}
+	if last >= 0 {
+		if residue := q.Gross.Sub(sum); !residue.IsZero() {
+			lines[last].Amount = cur.Round(lines[last].Amount.Add(residue), q.RoundingMode)
+		}
+	}
+
+	return lines, nil
+}
+
+// lineSections sums the included rows per named section, in the order the
+// rows list them. Quotes without named sections have none.
+func lineSections(lines []customerLine) []SectionTotal {
+	out := []SectionTotal{}
+	for _, ln := range lines {
+		if ln.Item == nil {
+			continue
+		}
+		if len(out) == 0 || out[len(out)-1].Name != ln.Section {
+			out = append(out, SectionTotal{Name: ln.Section, Subtotal: money.Zero})
+		}
+		if !ln.excluded() {
+			out[len(out)-1].Subtotal = out[len(out)-1].Subtotal.Add(ln.Amount)
+		}
+	}
+	if len(out) == 1 && out[0].Name == "" {
+		return []SectionTotal{}
+	}
+	return out
+}
+
+type quoteDocument struct {
+	Quote      Quote
+	Client     *clients.Client
+	Logo       []byte
+	FooterText *string
+}
+
+// Layout constants, in points.
+const (
+	pdfMargin    = 50.0
+	pdfRight     = pdf.PageWidth - pdfMargin
+	pdfBottom    = pdf.PageHeight - 90
+	pdfRowHeight = 16.0
+)
+
+func (d quoteDocument) render(out *bytes.Buffer) error {
+	q := d.Quote
+
+	lines, err := customerLines(q)
+	if err != nil {
+		return err
+	}
+
+	doc := pdf.New()
+	page := doc.AddPage()
+	pages := []*pdf.Page{page}
+	y := pdfMargin
+
+	// Header: logo on the left, quote identification on the right.
+	if d.Logo != nil {
+		img, err := doc.AddJPEG(d.Logo)
+		if err != nil {
+			return err
+		}
+		wpx, hpx := img.Size()
+		w, h := fitBox(float64(wpx), float64(hpx), 150, 60)
+		page.Image(img, pdfMargin, y, w, h)
+	}
+
+	page.TextRight(pdfRight, y+18, 20, true, "QUOTE")
+	page.TextRight(pdfRight, y+36, 10, false, "No. "+quoteNumber(q))
+	page.TextRight(pdfRight, y+50, 10, false, "Date: "+q.CreatedAt.Format("2006-01-02"))
+	page.TextRight(pdfRight, y+64, 10, false, fmt.Sprintf("Revision: %d", q.Revision))
+	if q.ValidUntil != nil {
+		page.TextRight(pdfRight, y+78, 10, false, "Valid until: "+q.ValidUntil.Format("2006-01-02"))
+	}
+	y += 96
+
+	// Client block, with the job site alongside.
+	if d.Client != nil {
+		top := y
+		page.Text(pdfMargin, y, 9, true, "BILL TO")
+		y += 14
+		page.Text(pdfMargin, y, 11, true, d.Client.Name)
+		y += 14
+		email, phone := d.Client.Email, d.Client.Phone
+		if c := q.Contact; c != nil {
+			page.Text(pdfMargin, y, 10, false, pdf.Truncate("Attn: "+c.Name, 10, false, 240))
+			y += 13
+			email, phone = c.Email, c.Phone
+		}
+		for _, v := range []*string{email, phone} {
+			if v != nil && *v != "" {
+				page.Text(pdfMargin, y, 10, false, *v)
+				y += 13
+			}
+		}
+
+		if a := q.SiteAddress; a != nil {
+			const siteX = 320.0
+			sy := top
+			page.Text(siteX, sy, 9, true, "JOB SITE")
+			sy += 14
+			for _, l := range addressLines(a.AddressFields) {
+				page.Text(siteX, sy, 10, false, pdf.Truncate(l, 10, false, pdfRight-siteX))
+				sy += 13
+			}
+			y = max(y, sy)
+		}
+		y += 12
+	}
+
+	// Line items.
+	const (
+		colQty   = 345.0
+		colUnit  = 355.0
+		colPrice = 470.0
+	)
+	tableHeader := func(p *pdf.Page, y float64) float64 {
+		p.FillRect(pdfMargin, y, pdfRight-pdfMargin, pdfRowHeight+2, 0.92)
+		ty := y + 12
+		p.Text(pdfMargin+4, ty, 9, true, "DESCRIPTION")
+		p.TextRight(colQty, ty, 9, true, "QTY")
+		p.Text(colUnit, ty, 9, true, "UNIT")
+		p.TextRight(colPrice, ty, 9, true, "UNIT PRICE")
+		p.TextRight(pdfRight-4, ty, 9, true, "AMOUNT ("+q.Currency+")")
+		return y + pdfRowHeight + 8
+	}
+
+	// With named sections each one gets a heading and a subtotal row.
+	sections := lineSections(lines)
+	subtotals := map[string]money.Decimal{}
+	for _, s := range sections {
+		subtotals[s.Name] = s.Subtotal
+	}
+	row := func() {
+		if y > pdfBottom {
+			page = doc.AddPage()
+			pages = append(pages, page)
+			y = tableHeader(page, pdfMargin)
+		}
+	}
+	sectionEnd := func(i int) bool {
+		ln := lines[i]
+		return len(sections) > 0 && ln.Item != nil &&
+			(i+1 == len(lines) || lines[i+1].Item == nil || lines[i+1].Section != ln.Section)
+	}
+
+	y = tableHeader(page, y)
+	for i, ln := range lines {
+		if len(sections) > 0 && ln.Item != nil && (i == 0 || lines[i-1].Section != ln.Section) {
+			row()
+			name := ln.Section
+			if name == "" {
+				name = "Other"
+			}
+			page.Text(pdfMargin+4, y, 10, true, pdf.Truncate(name, 10, true, pdfRight-pdfMargin-8))
+			y += pdfRowHeight
+		}
+		if len(sections) > 0 && ln.Item == nil && i > 0 && lines[i-1].Item != nil {
+			y += 4
+		}
+
+		row()
+		name := ln.Name
+		switch {
+		case ln.excluded() && ln.Group != "":
+			name += " (alternative)"
+		case ln.excluded():
+			name += " (optional)"
+		}
+		page.Text(pdfMargin+4, y, 10, false, pdf.Truncate(name, 10, false, colQty-pdfMargin-50))
+		page.TextRight(colQty, y, 10, false, ln.Qty.String())
+		page.Text(colUnit, y, 10, false, pdf.Truncate(ln.Unit, 10, false, 50))
+		page.TextRight(colPrice, y, 10, false, formatAmount(ln.UnitPrice))
+		page.TextRight(pdfRight-4, y, 10, false, formatAmount(ln.Amount))
+		y += pdfRowHeight
+
+		if sectionEnd(i) {
+			row()
+			page.Text(colUnit, y, 9, true, "Subtotal")
+			page.TextRight(pdfRight-4, y, 9, true, formatAmount(subtotals[ln.Section]))
+			y += pdfRowHeight + 4
+		}
+	}
+
+	// Totals.
+	if y+float64(len(q.Taxes)+6)*pdfRowHeight > pdfBottom {
+		page = doc.AddPage()
+		pages = append(pages, page)
+		y = pdfMargin
+	}
+	page.Line(colUnit, y-6, pdfRight, y-6, 0.5)
+	y += 8
+
+	cur := quoteCurrency(q)
+	totals := [][2]string{}
+	if !q.DiscountAmount.IsZero() {
+		totals = append(totals, [2]string{"Discount", formatAmount(cur.Round(q.DiscountAmount.Neg(), q.RoundingMode))})
+	}
+	// With tax-inclusive prices the rows already carry the additive taxes:
+	// only withholdings change the total, the rest is shown as "Includes".
+	included := [][2]string{}
+	if !q.PricesIncludeTax {
+		totals = append(totals, [2]string{"Subtotal", formatAmount(cur.Round(q.Subtotal, q.RoundingMode))})
+	}
+	for _, t := range q.Taxes {
+		if t.Base.IsZero() && t.Amount.IsZero() {
+			continue // only applies to lines not chosen
+		}
+		row := [2]string{t.Name + " (" + t.Rate.Trim().String() + "%)", formatAmount(cur.Round(t.Amount, q.RoundingMode))}
+		if q.PricesIncludeTax && !t.Withholding {
+			row[0] = "Includes " + row[0]
+			included = append(included, row)
+			continue
+		}
+		totals = append(totals, row)
+	}
+	for _, t := range totals {
+		page.Text(colUnit, y, 10, false, pdf.Truncate(t[0], 10, false, 115))
+		page.TextRight(pdfRight-4, y, 10, false, t[1])
+		y += pdfRowHeight
+	}
+	page.Text(colUnit, y+2, 12, true, "Total "+q.Currency)
+	page.TextRight(pdfRight-4, y+2, 12, true, formatAmount(cur.Round(q.Total, q.RoundingMode)))
+	y += 2 * pdfRowHeight
+
+	if len(included) > 0 {
+		included = append(included, [2]string{"Net of tax", formatAmount(cur.Round(q.Subtotal, q.RoundingMode))})
+		for _, t := range included {
+			page.Text(colUnit, y, 9, false, pdf.Truncate(t[0], 9, false, 115))
+			page.TextRight(pdfRight-4, y, 9, false, t[1])
+			y += pdfRowHeight
+		}
+		y += pdfRowHeight
+	}
+
+	// Notes.
+	if q.Notes != nil && strings.TrimSpace(*q.Notes) != "" {
+		page.Text(pdfMargin, y, 9, true, "NOTES")
+		y += 14
+		for _, l := range pdf.Wrap(*q.Notes, 10, false, pdfRight-pdfMargin) {
+			if y > pdfBottom {
+				page = doc.AddPage()
+				pages = append(pages, page)
+				y = pdfMargin
+			}
+			page.Text(pdfMargin, y, 10, false, l)
+			y += 13
+		}
+	}
+
+	// Footer on every page.
+	for i, p := range pages {
+		fy := pdf.PageHeight - 40
+		if d.FooterText != nil && *d.FooterText != "" {
+			for j, l := range pdf.Wrap(*d.FooterText, 8, false, pdfRight-pdfMargin-60) {
+				if j == 2 {
+					break
+				}
+				p.Text(pdfMargin, fy+float64(j)*10, 8, false, l)
+			}
+		}
+		p.TextRight(pdfRight, fy, 8, false, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+	}
+
+	return doc.Write(out)
+}
+
+// quoteNumber is the short human-facing identifier printed on documents.
+func quoteNumber(q Quote) string {
+	return "Q-" + strings.ToUpper(q.ID.String()[:8])
+}
+
+// fitBox scales (w, h) down to fit maxW×maxH keeping the aspect ratio.
+func fitBox(w, h, maxW, maxH float64) (float64, float64) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	scale := maxW / w
+	if s := maxH / h; s < scale {
+		scale = s
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	return w * scale, h * scale
+}
+
+// formatAmount adds thousands separators to a decimal:
+// 1234567.50 -> "1,234,567.50".
+func formatAmount(d money.Decimal) string {
+	s := d.String()
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+
+	out := b.String() + frac
+	if neg {
+		out = "-" + out
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package quotes
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// PreviewQuote computes the totals of a quote without saving it. It accepts
+// the same body as PostQuote and runs the same validation, but writes
+// nothing and does not count against the plan's monthly quote limit.
+func PreviewQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		in, ok := decodeQuoteIn(w, r, pool, ownerID)
+		if !ok {
+			return
+		}
+
+		p, ok := prepareQuote(w, r, pool, ownerID, &in)
+		if !ok {
+			return
+		}
+
+		profit, marginPct := profitOf(p.calc.Net, p.calc.Cost, p.calc.CostComplete)
+
+		utils.WriteJSON(w, http.StatusOK, QuotePreview{
+			Items:              p.calc.Items,
+			Sections:           p.calc.Sections,
+			LaborLines:         p.calc.LaborLines,
+			Labor:              p.calc.Labor,
+			MarginPct:          in.MarginPct.Round(2),
+			Margin:             p.calc.Margin,
+			Cost:               p.calc.Cost,
+			CostComplete:       p.calc.CostComplete,
+			GrossProfit:        profit,
+			EffectiveMarginPct: marginPct,
+			PricesIncludeTax:   in.PricesIncludeTax,
+			Gross:              p.calc.Gross,
+			DiscountAmount:     p.calc.Discount,
+			Subtotal:           p.calc.Net,
+			TaxIDs:             p.calc.TaxIDs,
+			Taxes:              p.calc.Taxes,
+			Tax:                p.calc.Total.Sub(p.calc.Net),
+			Total:              p.calc.Total,
+			Currency:           in.Currency,
+			RoundingMode:       p.settings.RoundingMode,
+			ValidUntil:         p.validUntil,
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package quotes
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// maxSignatureLen bounds, in characters, the typed signature stored with a
+// response.
+const maxSignatureLen = 200
+
+// PublicGetQuote shows a sent quote to the customer holding its share link.
+// No authentication: the unguessable public_id is the credential.
+func PublicGetQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, clientName, err := getPublicQuote(r, pool)
+		if err != nil {
+			writePublicErr(w, err)
+			return
+		}
+
+		out, err := publicView(q, clientName)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "parse_error", "stored items invalid JSON")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, out)
+	}
+}
+
+// PublicAcceptQuote records the customer's acceptance.
+func PublicAcceptQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return respondQuote(pool, StatusAccepted)
+}
+
+// PublicRejectQuote records the customer's rejection.
+func PublicRejectQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return respondQuote(pool, StatusRejected)
+}
+
+func respondQuote(pool *pgxpool.Pool, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		publicID := strings.TrimSpace(chi.URLParam(r, "public_id"))
+
+		var in RespondQuoteIn
+		// The body is optional.
+		if err := utils.DecodeJSON(w, r, &in); err != nil && !errors.Is(err, utils.ErrEmptyBody) {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		if in.Options != nil && status != StatusAccepted {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "options can only be chosen when accepting")
+			return
+		}
+
+		var signature *string
+		if in.SignatureName != nil {
+			s := strings.TrimSpace(*in.SignatureName)
+			if utf8.RuneCountInString(s) > maxSignatureLen {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "signature_name is too long")
+				return
+			}
+			if s != "" {
+				signature = &s
+			}
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		// Only a quote awaiting an answer can be answered, and only a valid one
+		// accepted; the checks in the WHERE clause make concurrent responses
+		// race-free.
+		var q Quote
+		err = scanQuote(tx.QueryRow(r.Context(), `
+			UPDATE quotes
+			SET status=$2, responded_at=now(), response_ip=$3, signature_name=$4, updated_at=now()
+			WHERE public_id=$1 AND status=$5
+			  AND ($2 <> $6 OR valid_until IS NULL OR valid_until >= CURRENT_DATE)
+			RETURNING `+quoteColumns, publicID, status, clientIP(r), signature, StatusSent, StatusAccepted), &q)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			var (
+				current    string
+				validUntil *time.Time
+			)
+			err = pool.QueryRow(r.Context(), `SELECT status, valid_until FROM quotes WHERE public_id=$1 AND status <> 'draft'`, publicID).Scan(&current, &validUntil)
+			if err == nil {
+				if current == StatusExpired || (current == StatusSent && isExpired(validUntil)) {
+					utils.WriteErr(w, http.StatusConflict, "quote_expired", "this quote has expired and can no longer be accepted")
+					return
+				}
+				writeTransitionErr(w, current, status)
+				return
+			}
+		}
+
+		if err != nil {
+			writePublicErr(w, err)
+			return
+		}
+
+		if status == StatusAccepted {
+			q, err = acceptOptions(r.Context(), tx, q, in.Options)
+			var ce choiceError
+			if errors.As(err, &ce) {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", ce.Error())
+				return
+			}
+			if err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+		}
+
+		if err := recordStatusEvent(r.Context(), tx, q.ID, StatusSent, status, actorCustomer, nil); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		log.Printf("Quote %s %s by customer", q.ID.String(), status)
+
+		var clientName *string
+		if q.ClientID != nil {
+			var name string
+			if err := pool.QueryRow(r.Context(), `SELECT name FROM clients WHERE id=$1`, *q.ClientID).Scan(&name); err == nil {
+				clientName = &name
+			}
+		}
+
+		out, err := publicView(q, clientName)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "parse_error", "stored items invalid JSON")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, out)
+	}
+}
+
+func getPublicQuote(r *http.Request, pool *pgxpool.Pool) (Quote, *string, error) {
+	publicID := strings.TrimSpace(chi.URLParam(r, "public_id"))
+
+	var (
+		q          Quote
+		clientName *string
+	)
+	err := pool.QueryRow(r.Context(), `
+		SELECT `+prefixColumns("q", quoteColumns)+`, c.name
+		FROM quotes q LEFT JOIN clients c ON c.id = q.client_id
+		WHERE q.public_id=$1 AND q.status <> 'draft'
+	`, publicID).Scan(append(quoteDest(&q), &clientName)...)
+	return q, clientName, err
+}
+
+func writePublicErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+func publicView(q Quote, clientName *string) (PublicQuote, error) {
+	lines, err := customerLines(q)
+	if err != nil {
+		return PublicQuote{}, err
+	}
+
+	cur := quoteCurrency(q)
+
+	return PublicQuote{
+		Number:           quoteNumber(q),
+		Status:           q.Status,
+		ClientName:       clientName,
+		Contact:          q.Contact,
+		SiteAddress:      q.SiteAddress,
+		Lines:            lines,
+		Sections:         lineSections(lines),
+		PricesIncludeTax: q.PricesIncludeTax,
+		Gross:            cur.Round(q.Gross, q.RoundingMode),
+		Discount:         cur.Round(q.DiscountAmount, q.RoundingMode),
+		Subtotal:         cur.Round(q.Subtotal, q.RoundingMode),
+		TaxPct:           q.TaxPct,
+		Taxes:            q.Taxes,
+		Tax:              cur.Round(q.Total.Sub(q.Subtotal), q.RoundingMode),
+		Total:            cur.Round(q.Total, q.RoundingMode),
+		Currency:         q.Currency,
+		Notes:            q.Notes,
+		ValidUntil:       q.ValidUntil,
+		CreatedAt:        q.CreatedAt,
+		RespondedAt:      q.RespondedAt,
+		SignatureName:    q.SignatureName,
+	}, nil
+}
+
+// newPublicID returns an unguessable, URL-safe share token (192 random bits).
+func newPublicID() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// clientIP returns the caller's IP. middleware.RealIP has already replaced
+// RemoteAddr with X-Forwarded-For / X-Real-IP when present.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -1 +1,150 @@
 package quotes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dbtx is satisfied by both *pgxpool.Pool and pgx.Tx, so helpers can run
+// inside or outside a transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// quoteColumns lists the columns read into a Quote, in scanQuote order.
+const quoteColumns = `id, client_id, contact_id, site_address_id, contact, site_address, items, measurements, labor_hours, labor_rate, labor_lines, margin_pct, tax_pct,
+	discount, prices_include_tax, gross, discount_amount, net, cost, cost_complete, gross_profit, effective_margin_pct, sections, chosen_options, tax_ids, taxes, subtotal, total, currency, rounding_mode, notes, valid_until, template_id, source_quote_id, public_id, status, revision,
+	responded_at, response_ip, signature_name, created_at, updated_at`
+
+// scanQuote reads a row selected with quoteColumns. It accepts both pgx.Row and
+// pgx.Rows so single lookups and listings share the same column order.
+func scanQuote(row pgx.Row, q *Quote) error {
+	return row.Scan(quoteDest(q)...)
+}
+
+// quoteDest returns the scan destinations matching quoteColumns, for queries
+// that select extra columns after them.
+func quoteDest(q *Quote) []any {
+	return []any{
+		&q.ID,
+		&q.ClientID,
+		&q.ContactID,
+		&q.SiteAddressID,
+		&q.Contact,
+		&q.SiteAddress,
+		&q.Items,
+		&q.Measurements,
+		&q.LaborHours,
+		&q.LaborRate,
+		&q.LaborLines,
+		&q.MarginPct,
+		&q.TaxPct,
+		&q.Discount,
+		&q.PricesIncludeTax,
+		&q.Gross,
+		&q.DiscountAmount,
+		&q.Net,
+		&q.Cost,
+		&q.CostComplete,
+		&q.GrossProfit,
+		&q.EffectiveMarginPct,
+		&q.Sections,
+		&q.ChosenOptions,
+		&q.TaxIDs,
+		&q.Taxes,
+		&q.Subtotal,
+		&q.Total,
+		&q.Currency,
+		&q.RoundingMode,
+		&q.Notes,
+		&q.ValidUntil,
+		&q.TemplateID,
+		&q.SourceQuoteID,
+		&q.PublicID,
+		&q.Status,
+		&q.Revision,
+		&q.RespondedAt,
+		&q.ResponseIP,
+		&q.SignatureName,
+		&q.CreatedAt,
+		&q.UpdatedAt,
+	}
+}
+
+// prefixColumns qualifies every column in a column list with a table alias,
+// e.g. prefixColumns("q", "id, status") -> "q.id, q.status".
+func prefixColumns(alias, cols string) string {
+	parts := strings.Split(cols, ",")
+	for i, c := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(c)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// revisionColumns lists the columns read into a Revision, in scanRevision order.
+const revisionColumns = `quote_id, number, snapshot, created_by, created_at`
+
+func scanRevision(row pgx.Row, rev *Revision) error {
+	return row.Scan(&rev.QuoteID, &rev.Number, &rev.Snapshot, &rev.CreatedBy, &rev.CreatedAt)
+}
+
+// insertRevision stores the snapshot of q under q.Revision. Callers bump
+// quotes.revision in the same transaction so numbers never collide. actor is
+// nil for changes made by the customer.
+func insertRevision(ctx context.Context, db dbtx, q Quote, actor *uuid.UUID) error {
+	snap, err := json.Marshal(snapshotOf(q))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO quote_revisions (quote_id, number, snapshot, created_by)
+		VALUES ($1, $2, $3, $4)
+	`, q.ID, q.Revision, snap, actor)
+	return err
+}
+
+// quoteInOf returns the content of a stored quote as calcTotals input.
+func quoteInOf(q Quote) (CreateQuoteIn, error) {
+	var items []QuoteItem
+	if err := json.Unmarshal(q.Items, &items); err != nil {
+		return CreateQuoteIn{}, err
+	}
+	return CreateQuoteIn{
+		TemplateID:       q.TemplateID,
+		ClientID:         q.ClientID,
+		ContactID:        q.ContactID,
+		SiteAddressID:    q.SiteAddressID,
+		Items:            items,
+		Measurements:     q.Measurements,
+		LaborHours:       q.LaborHours,
+		LaborRate:        q.LaborRate,
+		LaborLines:       q.LaborLines,
+		MarginPct:        q.MarginPct,
+		TaxPct:           q.TaxPct,
+		TaxIDs:           q.TaxIDs,
+		PricesIncludeTax: q.PricesIncludeTax,
+		Currency:         q.Currency,
+		Discount:         q.Discount,
+		Notes:            q.Notes,
+		storedUnits:      unitsOf(items),
+		storedTaxes:      taxDefsOf(q.Taxes),
+	}, nil
+}
+
+// unitsOf returns the units the items are measured in.
+func unitsOf(items []QuoteItem) map[string]bool {
+	out := map[string]bool{}
+	for _, it := range items {
+		if u := strings.TrimSpace(it.Unit); u != "" {
+			out[u] = true
+		}
+	}
+	return out
+}
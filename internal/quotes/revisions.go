@@ -0,0 +1,338 @@
+package quotes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// ListRevisions returns the full revision history of a quote, oldest first.
+func ListRevisions(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		if !quoteExists(w, r, pool, ownerID, id) {
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `
+			SELECT `+revisionColumns+` FROM quote_revisions
+			WHERE quote_id=$1 ORDER BY number
+		`, id)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []Revision{}
+		for rows.Next() {
+			var rev Revision
+			if err := scanRevision(rows, &rev); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, rev)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+	}
+}
+
+// GetRevision returns a single numbered revision of a quote.
+func GetRevision(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		n, err := strconv.Atoi(chi.URLParam(r, "n"))
+		if err != nil || n <= 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid revision number")
+			return
+		}
+
+		rev, err := getRevision(r, pool, ownerID, id, n)
+		if err != nil {
+			writeRevisionErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, rev)
+	}
+}
+
+// DiffRevisions compares two revisions of a quote. Query params "from" and
+// "to" select the revisions; "to" defaults to the latest and "from" to the
+// one before it. Revision 1 has none, so it is compared with itself and the
+// diff is empty.
+func DiffRevisions(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var latest int
+		err = pool.QueryRow(r.Context(), `SELECT revision FROM quotes WHERE id=$1 AND owner_id=$2`, id, ownerID).Scan(&latest)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+				return
+			}
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		to, err := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("to"), strconv.Itoa(latest)))
+		if err != nil || to <= 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid to")
+			return
+		}
+
+		from, err := strconv.Atoi(utils.DefaultIfEmpty(r.URL.Query().Get("from"), strconv.Itoa(max(to-1, 1))))
+		if err != nil || from <= 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid from")
+			return
+		}
+
+		a, err := getRevision(r, pool, ownerID, id, from)
+		if err != nil {
+			writeRevisionErr(w, err)
+			return
+		}
+
+		b, err := getRevision(r, pool, ownerID, id, to)
+		if err != nil {
+			writeRevisionErr(w, err)
+			return
+		}
+
+		changes, err := diffJSON(a.Snapshot, b.Snapshot)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "parse_error", "stored snapshot invalid JSON")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, RevisionDiff{QuoteID: id, From: from, To: to, Changes: changes})
+	}
+}
+
+func getRevision(r *http.Request, pool *pgxpool.Pool, ownerID, quoteID uuid.UUID, n int) (Revision, error) {
+	var rev Revision
+	err := scanRevision(pool.QueryRow(r.Context(), `
+		SELECT `+revisionColumns+` FROM quote_revisions rv
+		WHERE rv.quote_id=$1 AND rv.number=$2
+		  AND EXISTS (SELECT 1 FROM quotes q WHERE q.id=rv.quote_id AND q.owner_id=$3)
+	`, quoteID, n, ownerID), &rev)
+	return rev, err
+}
+
+func writeRevisionErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "revision not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+// quoteExists writes a 404 and returns false when the owner has no such quote.
+func quoteExists(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID, id uuid.UUID) bool {
+	var exists bool
+	if err := pool.QueryRow(r.Context(), `SELECT EXISTS (SELECT 1 FROM quotes WHERE id=$1 AND owner_id=$2)`, id, ownerID).Scan(&exists); err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return false
+	}
+	if !exists {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+		return false
+	}
+	return true
+}
+
+// snapshotOf extracts the revisioned content of a quote.
+func snapshotOf(q Quote) Snapshot {
+	return Snapshot{
+		ClientID:         q.ClientID,
+		Contact:          q.Contact,
+		SiteAddress:      q.SiteAddress,
+		Items:            q.Items,
+		Measurements:     q.Measurements,
+		LaborHours:       q.LaborHours,
+		LaborRate:        q.LaborRate,
+		LaborLines:       q.LaborLines,
+		MarginPct:        q.MarginPct,
+		TaxPct:           q.TaxPct,
+		Discount:         q.Discount,
+		PricesIncludeTax: q.PricesIncludeTax,
+		Gross:            q.Gross,
+		DiscountAmount:   q.DiscountAmount,
+		Net:              q.Net,
+		Cost:             q.Cost,
+		CostComplete:     q.CostComplete,
+		Sections:         q.Sections,
+		ChosenOptions:    q.ChosenOptions,
+		TaxIDs:           q.TaxIDs,
+		Taxes:            q.Taxes,
+		Subtotal:         q.Subtotal,
+		Total:            q.Total,
+		Currency:         q.Currency,
+		Notes:            q.Notes,
+		ValidUntil:       q.ValidUntil,
+	}
+}
+
+// diffJSON - Computes a structured diff between two JSON documents
+// Purpose: Reports field-level changes between quote snapshots without depending on the snapshot schema
+// Advantages:
+//   - Works for any snapshot shape, so new quote fields are diffed automatically
+//   - Amounts are compared by exact decimal value, never through float64
+//   - Deterministic output order (object keys sorted, arrays by index)
+//
+// Weaknesses:
+//   - Arrays are compared by position: inserting an item shifts every later index
+//   - Whole sub-objects are reported when a key only exists on one side
+func diffJSON(a, b json.RawMessage) ([]RevisionChange, error) {
+	va, err := decodeAny(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decodeAny(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []RevisionChange{}
+	diffValue("", va, vb, &changes)
+	return changes, nil
+}
+
+func decodeAny(raw json.RawMessage) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func diffValue(path string, a, b any, out *[]RevisionChange) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(av)+len(bv))
+			for k := range av {
+				keys = append(keys, k)
+			}
+			for k := range bv {
+				if _, dup := av[k]; !dup {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				x, inA := av[k]
+				y, inB := bv[k]
+				switch {
+				case !inA:
+					*out = append(*out, RevisionChange{Path: p, Op: "added", To: y})
+				case !inB:
+					*out = append(*out, RevisionChange{Path: p, Op: "removed", From: x})
+				default:
+					diffValue(p, x, y, out)
+				}
+			}
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			for i := 0; i < len(av) || i < len(bv); i++ {
+				p := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(av):
+					*out = append(*out, RevisionChange{Path: p, Op: "added", To: bv[i]})
+				case i >= len(bv):
+					*out = append(*out, RevisionChange{Path: p, Op: "removed", From: av[i]})
+				default:
+					diffValue(p, av[i], bv[i], out)
+				}
+			}
+			return
+		}
+	}
+
+	if !sameValue(a, b) {
+		*out = append(*out, RevisionChange{Path: path, Op: "changed", From: a, To: b})
+	}
+}
+
+// sameValue compares JSON scalars, treating decimals by value so "12.5" and
+// "12.50" are not reported as a change. Amounts are JSON strings since
+// money.Decimal, and plain numbers in older snapshots, so both forms count.
+func sameValue(a, b any) bool {
+	da, okA := decimalValue(a)
+	db, okB := decimalValue(b)
+	if okA && okB {
+		return da.Equal(db)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func decimalValue(v any) (money.Decimal, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return money.Decimal{}, false
+	}
+	d, err := money.Parse(s)
+	return d, err == nil
+}
@@ -0,0 +1,202 @@
+package quotes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+const (
+	StatusDraft    = "draft"
+	StatusSent     = "sent"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+)
+
+// Actions that move a quote between statuses. Each transition is reachable
+// through exactly one action, so e.g. a PATCH can never revert a sent quote.
+const (
+	actionSend    = "send"    // POST /quotes/{id}/send
+	actionRespond = "respond" // customer link, or the owner via PATCH status
+	actionExpire  = "expire"  // background sweep
+	actionRevise  = "revise"  // POST /quotes/{id}/revise
+)
+
+// Who caused a status change, stored in quote_status_events.actor_type.
+const (
+	actorUser     = "user"
+	actorCustomer = "customer"
+	actorSystem   = "system"
+)
+
+// transitions is the quote lifecycle: from -> to -> action allowed to do it.
+//
+//	draft -> sent -> accepted | rejected | expired
+//	sent | expired -> draft (revise)
+var transitions = map[string]map[string]string{
+	StatusDraft: {
+		StatusSent: actionSend,
+	},
+	StatusSent: {
+		StatusAccepted: actionRespond,
+		StatusRejected: actionRespond,
+		StatusExpired:  actionExpire,
+		StatusDraft:    actionRevise,
+	},
+	StatusExpired: {
+		StatusDraft: actionRevise,
+	},
+}
+
+var errIllegalTransition = errors.New("illegal status transition")
+
+// isKnownStatus reports whether s is a valid quote status.
+func isKnownStatus(s string) bool {
+	switch s {
+	case StatusDraft, StatusSent, StatusAccepted, StatusRejected, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// checkTransition returns errIllegalTransition unless action may move a
+// quote from -> to.
+func checkTransition(from, to, action string) error {
+	if transitions[from][to] != action {
+		return errIllegalTransition
+	}
+	return nil
+}
+
+// writeTransitionErr answers 409 for an illegal transition.
+func writeTransitionErr(w http.ResponseWriter, from, to string) {
+	utils.WriteErr(w, http.StatusConflict, "illegal_transition", "cannot change status from "+from+" to "+to)
+}
+
+// recordStatusEvent appends to the quote's status log. actorID is nil for
+// customer and system changes.
+func recordStatusEvent(ctx context.Context, db dbtx, quoteID uuid.UUID, from, to, actorType string, actorID *uuid.UUID) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO quote_status_events (quote_id, from_status, to_status, actor_type, actor_id)
+		VALUES ($1, $2, $3, $4, $5)
+	`, quoteID, from, to, actorType, actorID)
+	return err
+}
+
+// ReviseQuote moves a sent or expired quote back to draft so it can be
+// edited. The share link stops resolving until the quote is sent again.
+func ReviseQuote(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		var current string
+		err = tx.QueryRow(r.Context(), `SELECT status FROM quotes WHERE id=$1 AND owner_id=$2 FOR UPDATE`, id, ownerID).Scan(&current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErr(w, http.StatusNotFound, "not_found", "quote not found")
+				return
+			}
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := checkTransition(current, StatusDraft, actionRevise); err != nil {
+			writeTransitionErr(w, current, StatusDraft)
+			return
+		}
+
+		var q Quote
+		err = scanQuote(tx.QueryRow(r.Context(), `
+			UPDATE quotes SET status=$3, updated_at=now()
+			WHERE id=$1 AND owner_id=$2
+			RETURNING `+quoteColumns, id, ownerID, StatusDraft), &q)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := recordStatusEvent(r.Context(), tx, id, current, StatusDraft, actorUser, &ownerID); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, q)
+	}
+}
+
+// ListStatusEvents returns the status log of a quote, oldest first.
+func ListStatusEvents(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		if !quoteExists(w, r, pool, ownerID, id) {
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `
+			SELECT id, quote_id, from_status, to_status, actor_type, actor_id, created_at
+			FROM quote_status_events WHERE quote_id=$1 ORDER BY created_at, id
+		`, id)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []StatusEvent{}
+		for rows.Next() {
+			var ev StatusEvent
+			if err := rows.Scan(&ev.ID, &ev.QuoteID, &ev.FromStatus, &ev.ToStatus, &ev.ActorType, &ev.ActorID, &ev.CreatedAt); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, ev)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+	}
+}
@@ -0,0 +1,195 @@
+package quotes
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+
+	"github.com/google/uuid"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/taxes"
+)
+
+// TaxLine is one tax in a quote's breakdown. It snapshots the definition
+// used, so later edits to the account's taxes don't alter the quote. The
+// legacy quote-wide tax_pct appears as a line without TaxID.
+type TaxLine struct {
+	TaxID       *uuid.UUID    `json:"tax_id,omitempty"`
+	Name        string        `json:"name"`
+	Rate        money.Decimal `json:"rate"`
+	Compound    bool          `json:"compound"`
+	Withholding bool          `json:"withholding"`
+	Base        money.Decimal `json:"base"`
+	Amount      money.Decimal `json:"amount"` // negative for withholdings
+}
+
+// taxedLine is an amount subject to a set of taxes: an item or the labor.
+type taxedLine struct {
+	amount money.Decimal
+	taxIDs []uuid.UUID
+}
+
+// taxAcc accumulates one tax across lines with exact arithmetic.
+type taxAcc struct {
+	line   TaxLine
+	rate   *big.Rat
+	base   *big.Rat
+	amount *big.Rat
+}
+
+// applyTaxes - Computes the per-tax breakdown of a quote
+// Purpose: Taxes every line with its own tax set, including compound and withholding taxes
+// Advantages:
+//   - factor carries margin and the quote-level discount into each line's taxable base
+//   - Compound taxes see the simple (non-withholding) taxes of the same line, so exempt
+//     lines never leak into a stacked tax
+//   - With inclusive set, each line's base is back-calculated from its tax-inclusive amount
+//     using the line's own effective rate
+//   - Exact big.Rat accumulation, rounded once per tax
+//
+// Weaknesses:
+//   - Compound taxes don't stack on each other, only on simple taxes
+//   - Inclusive prices include additive taxes only; withholdings are always computed on top
+func applyTaxes(lines []taxedLine, factor *big.Rat, legacyPct money.Decimal, defs map[uuid.UUID]taxes.Rate,
+	inclusive bool, cur money.Currency, mode money.RoundingMode) []TaxLine {
+
+	order := []*taxAcc{}
+	accs := map[uuid.UUID]*taxAcc{}
+	acc := func(key uuid.UUID, line TaxLine) *taxAcc {
+		a, ok := accs[key]
+		if !ok {
+			a = &taxAcc{line: line, rate: line.Rate.Rat(), base: new(big.Rat), amount: new(big.Rat)}
+			accs[key] = a
+			order = append(order, a)
+		}
+		return a
+	}
+	add := func(a *taxAcc, base *big.Rat) *big.Rat {
+		amt := new(big.Rat).Mul(base, a.rate)
+		amt.Quo(amt, big.NewRat(100, 1))
+		if a.line.Withholding {
+			amt.Neg(amt)
+		}
+		a.base.Add(a.base, base)
+		a.amount.Add(a.amount, amt)
+		return amt
+	}
+
+	for _, ln := range lines {
+		base := new(big.Rat).Mul(ln.amount.Rat(), factor)
+		if inclusive {
+			base.Quo(base, inclusiveMultiplier(ln.taxIDs, legacyPct, defs))
+		}
+		simple := new(big.Rat)
+
+		if legacyPct.Sign() > 0 {
+			simple.Add(simple, add(acc(uuid.Nil, TaxLine{Name: "Tax", Rate: legacyPct}), base))
+		}
+
+		var compound []taxes.Rate
+		for _, id := range ln.taxIDs {
+			def := defs[id]
+			if def.Compound {
+				compound = append(compound, def)
+				continue
+			}
+			amt := add(acc(id, taxLineOf(def)), base)
+			if !def.Withholding {
+				simple.Add(simple, amt)
+			}
+		}
+
+		stacked := new(big.Rat).Add(base, simple)
+		for _, def := range compound {
+			add(acc(def.ID, taxLineOf(def)), stacked)
+		}
+	}
+
+	out := make([]TaxLine, 0, len(order))
+	for _, a := range order {
+		a.line.Base = money.FromRat(a.base, cur.MinorUnits, mode)
+		a.line.Amount = money.FromRat(a.amount, cur.MinorUnits, mode)
+		out = append(out, a.line)
+	}
+	return out
+}
+
+// inclusiveMultiplier is how much a tax-inclusive amount exceeds its base:
+// 1 + s + c×(1 + s), with s and c the summed simple and compound additive
+// rates of the line. Withholdings are not part of a tax-inclusive price.
+func inclusiveMultiplier(taxIDs []uuid.UUID, legacyPct money.Decimal, defs map[uuid.UUID]taxes.Rate) *big.Rat {
+	simple := legacyPct.Rat()
+	compound := new(big.Rat)
+	for _, id := range taxIDs {
+		def := defs[id]
+		switch {
+		case def.Withholding:
+		case def.Compound:
+			compound.Add(compound, def.Rate.Rat())
+		default:
+			simple.Add(simple, def.Rate.Rat())
+		}
+	}
+
+	hundred := big.NewRat(100, 1)
+	s := new(big.Rat).Quo(simple, hundred)
+	c := new(big.Rat).Quo(compound, hundred)
+
+	onePlusS := new(big.Rat).Add(big.NewRat(1, 1), s)
+	return onePlusS.Add(onePlusS, new(big.Rat).Mul(c, new(big.Rat).Add(big.NewRat(1, 1), s)))
+}
+
+func taxLineOf(def taxes.Rate) TaxLine {
+	id := def.ID
+	return TaxLine{TaxID: &id, Name: def.Name, Rate: def.Rate, Compound: def.Compound, Withholding: def.Withholding}
+}
+
+// taxDefsOf returns the tax definitions snapshotted in a quote's tax lines.
+func taxDefsOf(lines []TaxLine) map[uuid.UUID]taxes.Rate {
+	out := map[uuid.UUID]taxes.Rate{}
+	for _, t := range lines {
+		if t.TaxID != nil {
+			out[*t.TaxID] = taxes.Rate{ID: *t.TaxID, Name: t.Name, Rate: t.Rate, Compound: t.Compound, Withholding: t.Withholding}
+		}
+	}
+	return out
+}
+
+// withStoredTaxes returns defs completed with the stored definitions of
+// taxes that no longer exist. Current definitions take precedence.
+func withStoredTaxes(defs, stored map[uuid.UUID]taxes.Rate) map[uuid.UUID]taxes.Rate {
+	if len(stored) == 0 {
+		return defs
+	}
+	out := make(map[uuid.UUID]taxes.Rate, len(defs)+len(stored))
+	maps.Copy(out, stored)
+	maps.Copy(out, defs)
+	return out
+}
+
+// resolveTaxIDs checks that every id refers to a known tax and drops duplicates.
+func resolveTaxIDs(ids []uuid.UUID, defs map[uuid.UUID]taxes.Rate) ([]uuid.UUID, error) {
+	out := make([]uuid.UUID, 0, len(ids))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if _, ok := defs[id]; !ok {
+			return nil, fmt.Errorf("unknown tax %s", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// taxIDsOf lists every tax referenced by a quote, for loading definitions.
+func taxIDsOf(in CreateQuoteIn) []uuid.UUID {
+	ids := append([]uuid.UUID{}, in.TaxIDs...)
+	for _, it := range in.Items {
+		if it.TaxIDs != nil {
+			ids = append(ids, *it.TaxIDs...)
+		}
+	}
+	return ids
+}
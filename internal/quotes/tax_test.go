@@ -0,0 +1,365 @@
+package quotes
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/taxes"
+)
+
+// testTax returns a tax definition with a fresh id.
+func testTax(name, rate string, compound, withholding bool) taxes.Rate {
+	return taxes.Rate{ID: uuid.New(), Name: name, Rate: money.MustParse(rate), Compound: compound, Withholding: withholding}
+}
+
+func taxMap(defs ...taxes.Rate) map[uuid.UUID]taxes.Rate {
+	out := map[uuid.UUID]taxes.Rate{}
+	for _, d := range defs {
+		out[d.ID] = d
+	}
+	return out
+}
+
+func price(s string) *money.Decimal {
+	d := money.MustParse(s)
+	return &d
+}
+
+// PatchQuote recalculates a draft with the definitions stored in its tax
+// lines standing in for taxes deleted since, e.g. after a sent quote that
+// used the tax is revised back to draft.
+func TestCalcTotalsFallsBackToStoredTaxes(t *testing.T) {
+	iva := testTax("IVA", "16", false, false)
+	stored := []TaxLine{taxLineOf(iva)}
+
+	in := CreateQuoteIn{
+		Items:       []QuoteItem{{Name: "Tile", Qty: money.MustParse("1"), UnitPrice: price("100")}},
+		TaxIDs:      []uuid.UUID{iva.ID},
+		Currency:    "MXN",
+		storedTaxes: taxDefsOf(stored),
+	}
+
+	b, err := calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap()})
+	if err != nil {
+		t.Fatalf("calcTotals with a deleted tax: %v", err)
+	}
+	if b.Total.String() != "116.00" || len(b.Taxes) != 1 || b.Taxes[0].Name != "IVA" {
+		t.Errorf("total = %s, taxes = %+v; want 116.00 with IVA", b.Total, b.Taxes)
+	}
+
+	// A tax that still exists uses its current definition.
+	current := iva
+	current.Rate = money.MustParse("8")
+	b, err = calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap(current)})
+	if err != nil {
+		t.Fatalf("calcTotals: %v", err)
+	}
+	if b.Total.String() != "108.00" {
+		t.Errorf("total = %s, want 108.00 with the current rate", b.Total)
+	}
+
+	// Without a stored definition an unknown tax is still rejected.
+	in.storedTaxes = nil
+	if _, err := calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap()}); err == nil || !strings.Contains(err.Error(), "unknown tax") {
+		t.Errorf("calcTotals error = %v, want unknown tax", err)
+	}
+}
+
+// DuplicateQuote recalculates the source quote's content from quoteInOf,
+// which carries its tax snapshot along.
+func TestQuoteInOfKeepsDeletedTaxes(t *testing.T) {
+	iva := testTax("IVA", "16", false, false)
+	ret := testTax("Ret. ISR", "10", false, true)
+
+	items, err := json.Marshal([]QuoteItem{
+		{Name: "Design", Qty: money.MustParse("2"), UnitPrice: price("50")},
+		{Name: "Permit", Qty: money.MustParse("1"), UnitPrice: price("20"), TaxIDs: &[]uuid.UUID{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Quote{
+		Items:    items,
+		TaxIDs:   []uuid.UUID{iva.ID, ret.ID},
+		Taxes:    []TaxLine{taxLineOf(iva), taxLineOf(ret)},
+		Currency: "MXN",
+	}
+
+	in, err := quoteInOf(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap()})
+	if err != nil {
+		t.Fatalf("calcTotals of a quote whose taxes were deleted: %v", err)
+	}
+
+	// 120 + 16% of 100 - 10% of 100
+	if b.Net.String() != "120.00" || b.Total.String() != "126.00" {
+		t.Errorf("net = %s, total = %s; want 120.00 and 126.00", b.Net, b.Total)
+	}
+}
+
+func TestCalcTotalsInclusive(t *testing.T) {
+	iva := testTax("IVA", "16", false, false)
+	gst := testTax("GST", "5", false, false)
+	qst := testTax("QST", "9.975", true, false)
+	isr := testTax("Ret. ISR", "10", false, true)
+	defs := taxMap(iva, gst, qst, isr)
+	exempt := &[]uuid.UUID{}
+
+	tests := []struct {
+		name    string
+		in      CreateQuoteIn
+		net     string
+		taxes   []string // amounts, in order of first use
+		total   string
+		payable string // gross - discount: what the customer was quoted
+	}{
+		{
+			name: "simple",
+			in:   CreateQuoteIn{Items: []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("116")}}, TaxIDs: []uuid.UUID{iva.ID}},
+			net:  "100.00", taxes: []string{"16.00"}, total: "116.00", payable: "116.00",
+		},
+		{
+			// Each line's base is 8.6206…; the residue lands in the subtotal.
+			name: "rounding residue",
+			in: CreateQuoteIn{Items: []QuoteItem{
+				{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("10")},
+				{Name: "B", Qty: money.MustParse("1"), UnitPrice: price("10")},
+				{Name: "C", Qty: money.MustParse("1"), UnitPrice: price("10")},
+			}, TaxIDs: []uuid.UUID{iva.ID}},
+			net: "25.86", taxes: []string{"4.14"}, total: "30.00", payable: "30.00",
+		},
+		{
+			name: "compound and withholding",
+			in:   CreateQuoteIn{Items: []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("100")}}, TaxIDs: []uuid.UUID{gst.ID, qst.ID, isr.ID}},
+			// base 100 / (1.05 + 0.09975 × 1.05) = 86.5995…
+			net: "86.60", taxes: []string{"4.33", "-8.66", "9.07"}, total: "91.34", payable: "100.00",
+		},
+		{
+			name: "exempt line",
+			in: CreateQuoteIn{Items: []QuoteItem{
+				{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("116")},
+				{Name: "Permit", Qty: money.MustParse("1"), UnitPrice: price("50"), TaxIDs: exempt},
+			}, TaxIDs: []uuid.UUID{iva.ID}},
+			net: "150.00", taxes: []string{"16.00"}, total: "166.00", payable: "166.00",
+		},
+		{
+			name: "quote discount",
+			in: CreateQuoteIn{
+				Items:    []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("116")}},
+				TaxIDs:   []uuid.UUID{iva.ID},
+				Discount: &Discount{Type: DiscountPct, Value: money.MustParse("10")},
+			},
+			net: "90.00", taxes: []string{"14.40"}, total: "104.40", payable: "104.40",
+		},
+		{
+			name: "labor",
+			in: CreateQuoteIn{
+				Items:      []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("0")}},
+				LaborHours: money.MustParse("1"),
+				LaborRate:  money.MustParse("58"),
+				TaxIDs:     []uuid.UUID{iva.ID},
+			},
+			net: "50.00", taxes: []string{"8.00"}, total: "58.00", payable: "58.00",
+		},
+		{
+			name: "legacy tax_pct",
+			in:   CreateQuoteIn{Items: []QuoteItem{{Name: "A", Qty: money.MustParse("1"), UnitPrice: price("116")}}, TaxPct: money.MustParse("16")},
+			net:  "100.00", taxes: []string{"16.00"}, total: "116.00", payable: "116.00",
+		},
+	}
+	for _, tt := range tests {
+		tt.in.Currency = "MXN"
+		tt.in.PricesIncludeTax = true
+		b, err := calcTotals(tt.in, calcContext{Mode: money.RoundHalfUp, Taxes: defs})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b.Net.String() != tt.net || b.Total.String() != tt.total {
+			t.Errorf("%s: net = %s, total = %s; want %s, %s", tt.name, b.Net, b.Total, tt.net, tt.total)
+		}
+		if got := b.Gross.Sub(b.Discount).String(); got != tt.payable {
+			t.Errorf("%s: gross - discount = %s, want %s", tt.name, got, tt.payable)
+		}
+		if len(b.Taxes) != len(tt.taxes) {
+			t.Errorf("%s: %d tax lines, want %d", tt.name, len(b.Taxes), len(tt.taxes))
+			continue
+		}
+		for i, want := range tt.taxes {
+			if b.Taxes[i].Amount.String() != want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, b.Taxes[i].Name, b.Taxes[i].Amount, want)
+			}
+		}
+	}
+}
+
+// With tax-inclusive prices, the subtotal plus the additive taxes must equal
+// what the customer was quoted, whatever the rounding does to each figure,
+// and the subtotal must stay within the rounding of the exact base.
+func TestCalcTotalsInclusiveAddsUp(t *testing.T) {
+	iva := testTax("IVA", "16", false, false)
+	gst := testTax("GST", "5", false, false)
+	qst := testTax("QST", "9.975", true, false)
+	isr := testTax("Ret. ISR", "10", false, true)
+	retIVA := testTax("Ret. IVA", "10.6667", false, true)
+	defs := taxMap(iva, gst, qst, isr, retIVA)
+
+	sets := [][]uuid.UUID{
+		{iva.ID},
+		{gst.ID, qst.ID},
+		{iva.ID, isr.ID, retIVA.ID},
+	}
+	for _, ids := range sets {
+		for _, mode := range []money.RoundingMode{money.RoundHalfUp, money.RoundHalfEven, money.RoundUp} {
+			for cents := int64(1); cents < 5000; cents += 37 {
+				in := CreateQuoteIn{
+					Items: []QuoteItem{
+						{Name: "A", Qty: money.MustParse("3"), UnitPrice: price(money.New(cents, 2).String())},
+						{Name: "B", Qty: money.MustParse("0.5"), UnitPrice: price(money.New(cents+1, 2).String())},
+					},
+					MarginPct:        money.MustParse("12.5"),
+					TaxIDs:           ids,
+					PricesIncludeTax: true,
+					Currency:         "MXN",
+				}
+				b, err := calcTotals(in, calcContext{Mode: mode, Taxes: defs})
+				if err != nil {
+					t.Fatalf("%d cents: %v", cents, err)
+				}
+
+				payable := b.Gross.Sub(b.Discount)
+				additive, withheld := b.Net, payable
+				for _, tl := range b.Taxes {
+					if tl.Withholding {
+						withheld = withheld.Add(tl.Amount)
+					} else {
+						additive = additive.Add(tl.Amount)
+					}
+				}
+				if !additive.Equal(payable) || !withheld.Equal(b.Total) {
+					t.Fatalf("%d cents, %s: net %s + taxes = %s, total %s; quoted %s", cents, mode, b.Net, additive, b.Total, payable)
+				}
+
+				exact := money.New(cents, 2).Mul(money.MustParse("3")).Add(money.New(cents+1, 2).Mul(money.MustParse("0.5"))).Percent(money.MustParse("112.5")).Rat()
+				exact.Quo(exact, inclusiveMultiplier(ids, money.Zero, defs))
+				slack := money.New(int64(len(b.Taxes)+1), 2).Rat()
+				if diff := new(big.Rat).Sub(b.Net.Rat(), exact); diff.Abs(diff).Cmp(slack) > 0 {
+					t.Fatalf("%d cents, %s: net %s is %s away from the exact base", cents, mode, b.Net, diff.FloatString(4))
+				}
+			}
+		}
+	}
+}
+
+func TestCalcTotalsStacking(t *testing.T) {
+	gst := testTax("GST", "5", false, false)
+	qst := testTax("QST", "9.975", true, false)
+	eco := testTax("Eco", "1", true, false)
+	iva := testTax("IVA", "16", false, false)
+	isr := testTax("Ret. ISR", "10", false, true)
+	retIVA := testTax("Ret. IVA", "10.6667", false, true)
+	defs := taxMap(gst, qst, eco, iva, isr, retIVA)
+	item := func(amount string, ids ...uuid.UUID) QuoteItem {
+		it := QuoteItem{Name: "Item", Qty: money.MustParse("1"), UnitPrice: price(amount)}
+		if ids != nil {
+			it.TaxIDs = &ids
+		}
+		return it
+	}
+
+	tests := []struct {
+		name  string
+		in    CreateQuoteIn
+		taxes []string // "name base amount", in order of first use
+		total string
+	}{
+		{
+			name:  "compound on simple",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("100")}, TaxIDs: []uuid.UUID{gst.ID, qst.ID}},
+			taxes: []string{"GST 100.00 5.00", "QST 105.00 10.47"},
+			total: "115.47",
+		},
+		{
+			name:  "compound does not stack on a withholding",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("100")}, TaxIDs: []uuid.UUID{gst.ID, qst.ID, isr.ID}},
+			taxes: []string{"GST 100.00 5.00", "Ret. ISR 100.00 -10.00", "QST 105.00 10.47"},
+			total: "105.47",
+		},
+		{
+			name:  "compound taxes do not stack on each other",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("100")}, TaxIDs: []uuid.UUID{gst.ID, qst.ID, eco.ID}},
+			taxes: []string{"GST 100.00 5.00", "QST 105.00 10.47", "Eco 105.00 1.05"},
+			total: "116.52",
+		},
+		{
+			// The second line has no GST, so its QST is on its bare amount.
+			name:  "compound per line",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("100"), item("100", qst.ID)}, TaxIDs: []uuid.UUID{gst.ID, qst.ID}},
+			taxes: []string{"GST 100.00 5.00", "QST 205.00 20.45"},
+			total: "225.45",
+		},
+		{
+			name:  "withholdings",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("1000")}, TaxIDs: []uuid.UUID{iva.ID, isr.ID, retIVA.ID}},
+			taxes: []string{"IVA 1000.00 160.00", "Ret. ISR 1000.00 -100.00", "Ret. IVA 1000.00 -106.67"},
+			total: "953.33",
+		},
+		{
+			name:  "compound on the legacy tax_pct",
+			in:    CreateQuoteIn{Items: []QuoteItem{item("100")}, TaxPct: money.MustParse("5"), TaxIDs: []uuid.UUID{qst.ID}},
+			taxes: []string{"Tax 100.00 5.00", "QST 105.00 10.47"},
+			total: "115.47",
+		},
+		{
+			name: "labor takes the quote's taxes",
+			in: CreateQuoteIn{
+				Items:      []QuoteItem{item("100", iva.ID)},
+				LaborHours: money.MustParse("2"),
+				LaborRate:  money.MustParse("50"),
+				TaxIDs:     []uuid.UUID{gst.ID, qst.ID},
+			},
+			taxes: []string{"IVA 100.00 16.00", "GST 100.00 5.00", "QST 105.00 10.47"},
+			total: "231.47",
+		},
+	}
+	for _, tt := range tests {
+		tt.in.Currency = "MXN"
+		b, err := calcTotals(tt.in, calcContext{Mode: money.RoundHalfUp, Taxes: defs})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := []string{}
+		for _, tl := range b.Taxes {
+			got = append(got, tl.Name+" "+tl.Base.String()+" "+tl.Amount.String())
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.taxes, ", ") {
+			t.Errorf("%s: taxes = %q, want %q", tt.name, got, tt.taxes)
+		}
+		if b.Total.String() != tt.total {
+			t.Errorf("%s: total = %s, want %s", tt.name, b.Total, tt.total)
+		}
+	}
+}
+
+func TestCalcTotalsWithholdingsExceedTotal(t *testing.T) {
+	a := testTax("Ret. A", "60", false, true)
+	b := testTax("Ret. B", "60", false, true)
+	in := CreateQuoteIn{
+		Items:    []QuoteItem{{Name: "Item", Qty: money.MustParse("1"), UnitPrice: price("100")}},
+		TaxIDs:   []uuid.UUID{a.ID, b.ID},
+		Currency: "MXN",
+	}
+	_, err := calcTotals(in, calcContext{Mode: money.RoundHalfUp, Taxes: taxMap(a, b)})
+	if err == nil || err.Error() != "tax_ids: withholdings exceed the quote total" {
+		t.Errorf("error = %v, want withholdings exceed the quote total", err)
+	}
+}
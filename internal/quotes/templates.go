@@ -0,0 +1,419 @@
+package quotes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roblesvargas97/estimago/internal/accounts"
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/catalog"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/plans"
+	"github.com/roblesvargas97/estimago/internal/taxes"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+// templateColumns lists the columns read into a QuoteTemplate, in scanTemplate order.
+const templateColumns = `id, name, items, measurements, labor_hours, labor_rate, labor_lines, margin_pct, tax_pct,
+	tax_ids, prices_include_tax, discount, currency, notes, created_at, updated_at`
+
+func scanTemplate(row pgx.Row, t *QuoteTemplate) error {
+	return row.Scan(
+		&t.ID, &t.Name, &t.Items, &t.Measurements, &t.LaborHours, &t.LaborRate, &t.LaborLines, &t.MarginPct, &t.TaxPct,
+		&t.TaxIDs, &t.PricesIncludeTax, &t.Discount, &t.Currency, &t.Notes, &t.CreatedAt, &t.UpdatedAt,
+	)
+}
+
+func getTemplate(ctx context.Context, pool *pgxpool.Pool, ownerID, id uuid.UUID) (QuoteTemplate, error) {
+	var t QuoteTemplate
+	err := scanTemplate(pool.QueryRow(ctx, `SELECT `+templateColumns+` FROM quote_templates WHERE id=$1 AND owner_id=$2`, id, ownerID), &t)
+	return t, err
+}
+
+// quoteIn returns the template as the starting point of a new quote.
+func (c TemplateContent) quoteIn() CreateQuoteIn {
+	return CreateQuoteIn{
+		Items:            c.Items,
+		Measurements:     c.Measurements,
+		LaborHours:       c.LaborHours,
+		LaborRate:        c.LaborRate,
+		LaborLines:       c.LaborLines,
+		MarginPct:        c.MarginPct,
+		TaxPct:           c.TaxPct,
+		TaxIDs:           c.TaxIDs,
+		PricesIncludeTax: c.PricesIncludeTax,
+		Discount:         c.Discount,
+		Currency:         c.Currency,
+		Notes:            c.Notes,
+	}
+}
+
+// decodeQuoteIn decodes a create-quote body. With a template_id the body is
+// layered over the template, so the fields it carries override the
+// template's. On failure the error has been written and ok is false.
+func decodeQuoteIn(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID) (CreateQuoteIn, bool) {
+	var raw json.RawMessage
+	if err := utils.DecodeJSON(w, r, &raw); err != nil {
+		utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+		return CreateQuoteIn{}, false
+	}
+
+	var in CreateQuoteIn
+	if err := utils.DecodeJSONBytes(raw, &in); err != nil {
+		utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+		return CreateQuoteIn{}, false
+	}
+
+	if in.TemplateID == nil {
+		return in, true
+	}
+
+	if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+		writeTemplatesUnavailable(w)
+		return CreateQuoteIn{}, false
+	}
+
+	t, err := getTemplate(r.Context(), pool, ownerID, *in.TemplateID)
+	if err != nil {
+		writeTemplateErr(w, err)
+		return CreateQuoteIn{}, false
+	}
+
+	in = CreateQuoteIn{}
+	if err := layerJSON(t.quoteIn(), raw, &in); err != nil {
+		utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+		return CreateQuoteIn{}, false
+	}
+	in.storedUnits = unitsOf(t.Items)
+	return in, true
+}
+
+// layerJSON decodes into out the JSON of base with the top-level fields of
+// body replacing base's. Fields are replaced whole: decoding body straight
+// over base would merge objects and array elements into the old values.
+func layerJSON(base any, body json.RawMessage, out any) error {
+	b, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	var merged, fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	if b, err = json.Marshal(merged); err != nil {
+		return err
+	}
+	return utils.DecodeJSONBytes(b, out)
+}
+
+// checkTemplate normalizes a template and validates it with the same rules
+// as a quote, except that it may have no items yet. storedUnits are the
+// units of the template being updated, if any. On failure the error has been
+// written and ok is false.
+func checkTemplate(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, ownerID uuid.UUID, in *CreateTemplateIn, storedUnits map[string]bool) bool {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name is required")
+		return false
+	}
+
+	if !pctInRange(in.MarginPct) || !pctInRange(in.TaxPct) {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "margin_pct and tax_pct must be between 0 and 100")
+		return false
+	}
+
+	cur, ok := money.LookupCurrency(in.Currency)
+	if !ok {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "currency must be a valid ISO 4217 code")
+		return false
+	}
+	in.Currency = cur.Code
+
+	if in.Items == nil {
+		in.Items = []QuoteItem{}
+	}
+	if in.LaborLines == nil {
+		in.LaborLines = []LaborLine{}
+	}
+	if in.TaxIDs == nil {
+		in.TaxIDs = []uuid.UUID{}
+	}
+
+	ms, err := resolveMeasurements(in.Measurements)
+	if err != nil {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return false
+	}
+	in.Measurements = ms
+
+	settings, err := accounts.Get(r.Context(), pool, ownerID)
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return false
+	}
+
+	qin := in.quoteIn()
+	qin.storedUnits = storedUnits
+
+	taxDefs, err := taxes.GetMany(r.Context(), pool, ownerID, taxIDsOf(qin))
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return false
+	}
+
+	catalogDefs, err := catalog.GetMany(r.Context(), pool, ownerID, catalogIDsOf(qin.Items))
+	if err != nil {
+		utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+		return false
+	}
+
+	_, err = calcTotals(qin, calcContext{
+		Mode:       settings.RoundingMode,
+		Taxes:      taxDefs,
+		Catalog:    catalogDefs,
+		LaborRates: settings.LaborRates,
+	})
+	if err != nil {
+		utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return false
+	}
+	return true
+}
+
+func PostTemplate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+			writeTemplatesUnavailable(w)
+			return
+		}
+
+		var in CreateTemplateIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		if !checkTemplate(w, r, pool, ownerID, &in, nil) {
+			return
+		}
+
+		var t QuoteTemplate
+		err := scanTemplate(pool.QueryRow(r.Context(), `
+			INSERT INTO quote_templates (
+				owner_id, name, items, measurements, labor_hours, labor_rate, labor_lines, margin_pct, tax_pct,
+				tax_ids, prices_include_tax, discount, currency, notes
+			) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+			RETURNING `+templateColumns,
+			ownerID, in.Name, in.Items, in.Measurements, in.LaborHours, in.LaborRate, in.LaborLines, in.MarginPct, in.TaxPct,
+			in.TaxIDs, in.PricesIncludeTax, in.Discount, in.Currency, in.Notes,
+		), &t)
+
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a template with the same name already exists")
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, t)
+	}
+}
+
+func ListTemplates(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+			writeTemplatesUnavailable(w)
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `SELECT `+templateColumns+` FROM quote_templates WHERE owner_id=$1 ORDER BY name`, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []QuoteTemplate{}
+		for rows.Next() {
+			var t QuoteTemplate
+			if err := scanTemplate(rows, &t); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, t)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+	}
+}
+
+func GetTemplate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+			writeTemplatesUnavailable(w)
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		t, err := getTemplate(r.Context(), pool, ownerID, id)
+		if err != nil {
+			writeTemplateErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, t)
+	}
+}
+
+// PatchTemplate updates a template. The body has the shape of
+// CreateTemplateIn and is layered over the stored template, so omitted
+// fields keep their value and arrays are replaced as a whole. Quotes already
+// created from the template are not affected.
+func PatchTemplate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+			writeTemplatesUnavailable(w)
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var raw json.RawMessage
+		if err := utils.DecodeJSON(w, r, &raw); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		t, err := getTemplate(r.Context(), pool, ownerID, id)
+		if err != nil {
+			writeTemplateErr(w, err)
+			return
+		}
+
+		var in CreateTemplateIn
+		if err := layerJSON(CreateTemplateIn{Name: t.Name, TemplateContent: t.TemplateContent}, raw, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		if !checkTemplate(w, r, pool, ownerID, &in, unitsOf(t.Items)) {
+			return
+		}
+
+		err = scanTemplate(pool.QueryRow(r.Context(), `
+			UPDATE quote_templates SET
+				name=$3, items=$4, measurements=$5, labor_hours=$6, labor_rate=$7, labor_lines=$8, margin_pct=$9,
+				tax_pct=$10, tax_ids=$11, prices_include_tax=$12, discount=$13, currency=$14, notes=$15, updated_at=now()
+			WHERE id=$1 AND owner_id=$2
+			RETURNING `+templateColumns,
+			id, ownerID, in.Name, in.Items, in.Measurements, in.LaborHours, in.LaborRate, in.LaborLines, in.MarginPct, in.TaxPct,
+			in.TaxIDs, in.PricesIncludeTax, in.Discount, in.Currency, in.Notes,
+		), &t)
+
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a template with the same name already exists")
+			return
+		}
+		if err != nil {
+			writeTemplateErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, t)
+	}
+}
+
+// DeleteTemplate removes a template. Quotes created from it keep their
+// content; their template_id is cleared.
+func DeleteTemplate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if !plans.FromRequest(r).Has(plans.FeatureTemplates) {
+			writeTemplatesUnavailable(w)
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		tag, err := pool.Exec(r.Context(), `DELETE FROM quote_templates WHERE id=$1 AND owner_id=$2`, id, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteErr(w, http.StatusNotFound, "not_found", "template not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeTemplateErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "template not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+func writeTemplatesUnavailable(w http.ResponseWriter) {
+	utils.WriteErr(w, http.StatusForbidden, "feature_unavailable", "quote templates are not included in your plan")
+}
@@ -5,54 +5,322 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/taxes"
 )
 
 type QuoteItem struct {
-	Kind      string   `json:"kind"`
-	Name      string   `json:"name"`
-	Qty       float64  `json:"qty"`
-	Unit      string   `json:"unit"`
-	UnitPrice float64  `json:"unit_price"`
-	LineTotal *float64 `json:"line_total,omitempty"`
+	// CatalogItemID references the catalog item the line was filled from;
+	// SKU is copied from it.
+	CatalogItemID *uuid.UUID     `json:"catalog_item_id,omitempty"`
+	SKU           *string        `json:"sku,omitempty"`
+	Kind          string         `json:"kind"`
+	Name          string         `json:"name"`
+	Qty           money.Decimal  `json:"qty"`
+	QtyFormula    string         `json:"qty_formula,omitempty"` // computes qty from the quote's measurements
+	Unit          string         `json:"unit"`
+	UnitPrice     *money.Decimal `json:"unit_price"`          // absent: the catalog item's sell price, else 0
+	UnitCost      *money.Decimal `json:"unit_cost,omitempty"` // internal, never shown to customers
+	Discount      *Discount      `json:"discount,omitempty"`
+	TaxIDs        *[]uuid.UUID   `json:"tax_ids,omitempty"`    // overrides the quote's tax_ids; [] = exempt
+	LineTotal     *money.Decimal `json:"line_total,omitempty"` // after the line discount
+
+	// Section groups the item under a heading with its own subtotal. An
+	// optional item counts only when selected; of the items sharing a
+	// group exactly one counts, the selected one or else the first.
+	Section  string `json:"section,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Selected *bool  `json:"selected,omitempty"`
+}
+
+// price is the item's unit price, 0 when it has none.
+func (it QuoteItem) price() money.Decimal {
+	if it.UnitPrice == nil {
+		return money.Zero
+	}
+	return *it.UnitPrice
 }
 
 type CreateQuoteIn struct {
-	ClientID   *uuid.UUID  `json:"client_id"`
-	Items      []QuoteItem `json:"items"`
-	LaborHours float64     `json:"labor_hours"`
-	LaborRate  float64     `json:"labor_rate"`
-	MarginPct  float64     `json:"margin_pct"`
-	TaxPct     float64     `json:"tax_pct"`
-	Currency   string      `json:"currency"`
-	Notes      *string     `json:"notes"`
+	// TemplateID pre-fills the quote from a template; any other field in
+	// the request overrides the template's value.
+	TemplateID *uuid.UUID `json:"template_id"`
+	ClientID   *uuid.UUID `json:"client_id"`
+	// ContactID and SiteAddressID pick a contact and a job-site address of
+	// the client; both are snapshotted into the quote.
+	ContactID     *uuid.UUID    `json:"contact_id"`
+	SiteAddressID *uuid.UUID    `json:"site_address_id"`
+	Items         []QuoteItem   `json:"items"`
+	LaborHours    money.Decimal `json:"labor_hours"`
+	LaborRate     money.Decimal `json:"labor_rate"`
+	LaborLines    []LaborLine   `json:"labor_lines"` // added to labor_hours × labor_rate
+	// Measurements are named figures item qty_formula expressions refer to.
+	Measurements map[string]Measurement `json:"measurements"`
+	MarginPct    money.Decimal          `json:"margin_pct"`
+	TaxPct       money.Decimal          `json:"tax_pct"`
+	TaxIDs       []uuid.UUID            `json:"tax_ids"` // applied to labor and to items without their own
+	// PricesIncludeTax marks unit prices and the labor rate as tax-inclusive.
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	Currency         string    `json:"currency"`
+	Discount         *Discount `json:"discount"` // quote-level, applied before tax
+	Notes            *string   `json:"notes"`
+	ValidUntil       *string   `json:"valid_until"` // YYYY-MM-DD; defaults to the account's default_valid_days
+
+	// storedUnits are units of lines already saved on the template or quote
+	// this input comes from. They were accepted before the unit registry
+	// existed and are kept as entered when the registry does not know them.
+	storedUnits map[string]bool
+	// storedTaxes are the tax definitions snapshotted in that quote's tax
+	// lines. They stand in for taxes deleted since, so the quote can still
+	// be recalculated.
+	storedTaxes map[uuid.UUID]taxes.Rate
 }
 
+// UpdateQuoteIn is the body of PATCH. Changing the client clears the
+// contact and site address unless new ones are given; both can be cleared
+// with null.
 type UpdateQuoteIn struct {
-	ClientID   *uuid.UUID       `json:"client_id"`
-	Items      *[]QuoteItem     `json:"items"`
-	LaborHours *float64         `json:"labor_hours"`
-	LaborRate  *float64         `json:"labor_rate"`
-	MarginPct  *float64         `json:"margin_pct"`
-	TaxPct     *float64         `json:"tax_pct"`
-	Currency   *string          `json:"currency"`
-	Notes      *json.RawMessage `json:"notes"`
-	Status     *string          `json:"status"`
+	ClientID         *uuid.UUID              `json:"client_id"`
+	ContactID        *json.RawMessage        `json:"contact_id"`
+	SiteAddressID    *json.RawMessage        `json:"site_address_id"`
+	Items            *[]QuoteItem            `json:"items"`
+	Measurements     *map[string]Measurement `json:"measurements"`
+	LaborHours       *money.Decimal          `json:"labor_hours"`
+	LaborRate        *money.Decimal          `json:"labor_rate"`
+	LaborLines       *[]LaborLine            `json:"labor_lines"`
+	MarginPct        *money.Decimal          `json:"margin_pct"`
+	TaxPct           *money.Decimal          `json:"tax_pct"`
+	TaxIDs           *[]uuid.UUID            `json:"tax_ids"`
+	PricesIncludeTax *bool                   `json:"prices_include_tax"`
+	Currency         *string                 `json:"currency"`
+	Discount         *json.RawMessage        `json:"discount"`
+	Notes            *json.RawMessage        `json:"notes"`
+	ValidUntil       *json.RawMessage        `json:"valid_until"`
+	Status           *string                 `json:"status"`
 }
 
 type Quote struct {
-	ID         uuid.UUID       `json:"id"`
-	ClientID   *uuid.UUID      `json:"client_id"`
+	ID       uuid.UUID  `json:"id"`
+	ClientID *uuid.UUID `json:"client_id"`
+
+	// The contact and site address as they were when assigned; the ids are
+	// cleared if those are deleted, the snapshots kept.
+	ContactID     *uuid.UUID    `json:"contact_id"`
+	SiteAddressID *uuid.UUID    `json:"site_address_id"`
+	Contact       *QuoteContact `json:"contact"`
+	SiteAddress   *QuoteAddress `json:"site_address"`
+
 	Items      json.RawMessage `json:"items"`
-	LaborHours float64         `json:"labor_hours"`
-	LaborRate  float64         `json:"labor_rate"`
-	MarginPct  float64         `json:"margin_pct"`
-	TaxPct     string          `json:"tax_pct"`
-	Subtotal   float64         `json:"subtotal"`
-	Total      float64         `json:"total"`
-	Currency   string          `json:"currency"`
-	Notes      *string         `json:"notes"`
-	PublicID   *string         `json:"public_id"`
-	Status     string          `json:"status"`
-	CreatedAt  time.Time       `json:"created_at"`
-	UpdatedAt  time.Time       `json:"updated_at"`
+	LaborHours money.Decimal   `json:"labor_hours"`
+	LaborRate  money.Decimal   `json:"labor_rate"`
+	LaborLines []LaborLine     `json:"labor_lines"`
+	MarginPct  money.Decimal   `json:"margin_pct"`
+	TaxPct     money.Decimal   `json:"tax_pct"`
+	Discount   *Discount       `json:"discount"`
+
+	Measurements     map[string]Measurement `json:"measurements"`
+	PricesIncludeTax bool                   `json:"prices_include_tax"`
+
+	// Breakdown: gross - discount_amount = net = subtotal. When prices
+	// include tax, gross and discount_amount do too, and
+	// gross - discount_amount = subtotal + the additive taxes.
+	Gross          money.Decimal `json:"gross"`
+	DiscountAmount money.Decimal `json:"discount_amount"`
+	Net            money.Decimal `json:"net"`
+
+	// Internal profitability: cost is Σ qty × unit_cost over the selected
+	// items plus labor before margin, gross_profit is subtotal - cost and
+	// effective_margin_pct is gross_profit / subtotal. cost_complete is false
+	// when a selected item has no unit_cost; effective_margin_pct is then
+	// null, as it is for an empty subtotal.
+	Cost               money.Decimal  `json:"cost"`
+	CostComplete       bool           `json:"cost_complete"`
+	GrossProfit        money.Decimal  `json:"gross_profit"`
+	EffectiveMarginPct *money.Decimal `json:"effective_margin_pct"`
+
+	Sections      []SectionTotal `json:"sections"`
+	ChosenOptions []int          `json:"chosen_options"` // set when the customer accepts
+
+	TaxIDs        []uuid.UUID        `json:"tax_ids"`
+	Taxes         []TaxLine          `json:"taxes"`
+	Subtotal      money.Decimal      `json:"subtotal"`
+	Total         money.Decimal      `json:"total"`
+	Currency      string             `json:"currency"`
+	RoundingMode  money.RoundingMode `json:"rounding_mode"`
+	Notes         *string            `json:"notes"`
+	ValidUntil    *time.Time         `json:"valid_until"`
+	TemplateID    *uuid.UUID         `json:"template_id"`
+	SourceQuoteID *uuid.UUID         `json:"source_quote_id"` // set on duplicates
+	PublicID      *string            `json:"public_id"`
+	Status        string             `json:"status"`
+	Revision      int                `json:"revision"`
+
+	// Customer response recorded through the public link.
+	RespondedAt   *time.Time `json:"responded_at"`
+	ResponseIP    *string    `json:"response_ip"`
+	SignatureName *string    `json:"signature_name"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Snapshot is the content of a quote frozen at a given revision.
+type Snapshot struct {
+	ClientID         *uuid.UUID             `json:"client_id"`
+	Contact          *QuoteContact          `json:"contact"`
+	SiteAddress      *QuoteAddress          `json:"site_address"`
+	Items            json.RawMessage        `json:"items"`
+	Measurements     map[string]Measurement `json:"measurements"`
+	LaborHours       money.Decimal          `json:"labor_hours"`
+	LaborRate        money.Decimal          `json:"labor_rate"`
+	LaborLines       []LaborLine            `json:"labor_lines"`
+	MarginPct        money.Decimal          `json:"margin_pct"`
+	TaxPct           money.Decimal          `json:"tax_pct"`
+	Discount         *Discount              `json:"discount"`
+	PricesIncludeTax bool                   `json:"prices_include_tax"`
+	Gross            money.Decimal          `json:"gross"`
+	DiscountAmount   money.Decimal          `json:"discount_amount"`
+	Net              money.Decimal          `json:"net"`
+	Cost             money.Decimal          `json:"cost"`
+	CostComplete     bool                   `json:"cost_complete"`
+	Sections         []SectionTotal         `json:"sections"`
+	ChosenOptions    []int                  `json:"chosen_options"`
+	TaxIDs           []uuid.UUID            `json:"tax_ids"`
+	Taxes            []TaxLine              `json:"taxes"`
+	Subtotal         money.Decimal          `json:"subtotal"`
+	Total            money.Decimal          `json:"total"`
+	Currency         string                 `json:"currency"`
+	Notes            *string                `json:"notes"`
+	ValidUntil       *time.Time             `json:"valid_until"`
+}
+
+type Revision struct {
+	QuoteID   uuid.UUID       `json:"quote_id"`
+	Number    int             `json:"number"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+	CreatedBy *uuid.UUID      `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RevisionChange describes one difference between two snapshots. Path uses
+// dotted keys and [i] indexes, e.g. "items[2].unit_price".
+type RevisionChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"` // added, removed or changed
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+type RevisionDiff struct {
+	QuoteID uuid.UUID        `json:"quote_id"`
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []RevisionChange `json:"changes"`
+}
+
+// PublicQuote is what a customer sees through the share link: no internal
+// IDs, no owner data and margin folded into the prices.
+type PublicQuote struct {
+	Number           string         `json:"number"`
+	Status           string         `json:"status"`
+	ClientName       *string        `json:"client_name"`
+	Contact          *QuoteContact  `json:"contact"`
+	SiteAddress      *QuoteAddress  `json:"site_address"`
+	Lines            []customerLine `json:"lines"`
+	Sections         []SectionTotal `json:"sections"` // subtotals of the selected lines
+	PricesIncludeTax bool           `json:"prices_include_tax"`
+	Gross            money.Decimal  `json:"gross"`
+	Discount         money.Decimal  `json:"discount"`
+	Subtotal         money.Decimal  `json:"subtotal"`
+	TaxPct           money.Decimal  `json:"tax_pct"`
+	Taxes            []TaxLine      `json:"taxes"`
+	Tax              money.Decimal  `json:"tax"`
+	Total            money.Decimal  `json:"total"`
+	Currency         string         `json:"currency"`
+	Notes            *string        `json:"notes"`
+	ValidUntil       *time.Time     `json:"valid_until"`
+	CreatedAt        time.Time      `json:"created_at"`
+	RespondedAt      *time.Time     `json:"responded_at"`
+	SignatureName    *string        `json:"signature_name"`
+}
+
+// QuotePreview is the computed breakdown of a quote that was not saved.
+// Amounts are rounded to the currency's minor units like a stored quote.
+type QuotePreview struct {
+	Items              []QuoteItem        `json:"items"` // with line_total filled in
+	Sections           []SectionTotal     `json:"sections"`
+	LaborLines         []LaborLine        `json:"labor_lines"` // with rate and total filled in
+	Labor              money.Decimal      `json:"labor"`
+	MarginPct          money.Decimal      `json:"margin_pct"`
+	Margin             money.Decimal      `json:"margin"`
+	Cost               money.Decimal      `json:"cost"`
+	CostComplete       bool               `json:"cost_complete"`
+	GrossProfit        money.Decimal      `json:"gross_profit"`
+	EffectiveMarginPct *money.Decimal     `json:"effective_margin_pct"`
+	PricesIncludeTax   bool               `json:"prices_include_tax"`
+	Gross              money.Decimal      `json:"gross"`
+	DiscountAmount     money.Decimal      `json:"discount_amount"`
+	Subtotal           money.Decimal      `json:"subtotal"`
+	TaxIDs             []uuid.UUID        `json:"tax_ids"`
+	Taxes              []TaxLine          `json:"taxes"`
+	Tax                money.Decimal      `json:"tax"`
+	Total              money.Decimal      `json:"total"`
+	Currency           string             `json:"currency"`
+	RoundingMode       money.RoundingMode `json:"rounding_mode"`
+	ValidUntil         time.Time          `json:"valid_until"`
+}
+
+// RespondQuoteIn is the optional body of the public accept/reject endpoints.
+type RespondQuoteIn struct {
+	SignatureName *string `json:"signature_name"`
+	// Options are the item indexes of the optional items and alternatives
+	// chosen on accept. Omitted, the quote is accepted as sent.
+	Options *[]int `json:"options"`
+}
+
+type StatusEvent struct {
+	ID         uuid.UUID  `json:"id"`
+	QuoteID    uuid.UUID  `json:"quote_id"`
+	FromStatus string     `json:"from_status"`
+	ToStatus   string     `json:"to_status"`
+	ActorType  string     `json:"actor_type"`
+	ActorID    *uuid.UUID `json:"actor_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DuplicateQuoteIn is the optional body of POST /quotes/{id}/duplicate.
+type DuplicateQuoteIn struct {
+	ClientID *uuid.UUID `json:"client_id"` // defaults to the source quote's client
+}
+
+// TemplateContent is what a template pre-fills on a new quote.
+type TemplateContent struct {
+	Items            []QuoteItem            `json:"items"`
+	Measurements     map[string]Measurement `json:"measurements"` // defaults for the items' formulas
+	LaborHours       money.Decimal          `json:"labor_hours"`
+	LaborRate        money.Decimal          `json:"labor_rate"`
+	LaborLines       []LaborLine            `json:"labor_lines"`
+	MarginPct        money.Decimal          `json:"margin_pct"`
+	TaxPct           money.Decimal          `json:"tax_pct"`
+	TaxIDs           []uuid.UUID            `json:"tax_ids"`
+	PricesIncludeTax bool                   `json:"prices_include_tax"`
+	Discount         *Discount              `json:"discount"`
+	Currency         string                 `json:"currency"`
+	Notes            *string                `json:"notes"`
+}
+
+type QuoteTemplate struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	TemplateContent
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateTemplateIn is also the body of PATCH, which is layered over the
+// stored template so only the fields present change.
+type CreateTemplateIn struct {
+	Name string `json:"name"`
+	TemplateContent
 }
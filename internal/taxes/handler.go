@@ -0,0 +1,315 @@
+package taxes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/roblesvargas97/estimago/internal/auth"
+	"github.com/roblesvargas97/estimago/internal/money"
+	"github.com/roblesvargas97/estimago/internal/utils"
+)
+
+var hundred = money.NewFromInt(100)
+
+func validRate(r money.Decimal) bool {
+	return r.Sign() >= 0 && r.Cmp(hundred) <= 0
+}
+
+func PostRate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var in CreateRateIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		in.Name = strings.TrimSpace(in.Name)
+		if in.Name == "" {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name is required")
+			return
+		}
+		if !validRate(in.Rate) {
+			utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "rate must be between 0 and 100")
+			return
+		}
+		if in.Compound && in.Withholding {
+			writeCompoundWithholding(w)
+			return
+		}
+
+		var t Rate
+		err := scanRate(pool.QueryRow(r.Context(), `
+			INSERT INTO tax_rates (owner_id, name, rate, compound, withholding)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+rateColumns, ownerID, in.Name, in.Rate, in.Compound, in.Withholding), &t)
+
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a tax with the same name already exists")
+			return
+		}
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, t)
+	}
+}
+
+func ListRates(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		rows, err := pool.Query(r.Context(), `SELECT `+rateColumns+` FROM tax_rates WHERE owner_id=$1 ORDER BY name`, ownerID)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer rows.Close()
+
+		outs := []Rate{}
+		for rows.Next() {
+			var t Rate
+			if err := scanRate(rows, &t); err != nil {
+				utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+				return
+			}
+			outs = append(outs, t)
+		}
+
+		if err := rows.Err(); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, outs)
+	}
+}
+
+func GetRate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var t Rate
+		err = scanRate(pool.QueryRow(r.Context(), `SELECT `+rateColumns+` FROM tax_rates WHERE id=$1 AND owner_id=$2`, id, ownerID), &t)
+		if err != nil {
+			writeRateErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, t)
+	}
+}
+
+// PatchRate updates a tax definition. Quotes already computed keep the
+// definition they were computed with until they are recalculated.
+func PatchRate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		var in UpdateRateIn
+		if err := utils.DecodeJSON(w, r, &in); err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "bad_json", err.Error())
+			return
+		}
+
+		sets := []string{}
+		args := []any{}
+		idx := 1
+
+		if in.Name != nil {
+			name := strings.TrimSpace(*in.Name)
+			if name == "" {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "name cannot be empty")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("name=$%d", idx))
+			args = append(args, name)
+			idx++
+		}
+
+		if in.Rate != nil {
+			if !validRate(*in.Rate) {
+				utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "rate must be between 0 and 100")
+				return
+			}
+			sets = append(sets, fmt.Sprintf("rate=$%d", idx))
+			args = append(args, *in.Rate)
+			idx++
+		}
+
+		if in.Compound != nil {
+			sets = append(sets, fmt.Sprintf("compound=$%d", idx))
+			args = append(args, *in.Compound)
+			idx++
+		}
+
+		if in.Withholding != nil {
+			sets = append(sets, fmt.Sprintf("withholding=$%d", idx))
+			args = append(args, *in.Withholding)
+			idx++
+		}
+
+		if in.Compound != nil || in.Withholding != nil {
+			// A flag left out of the patch keeps its stored value. This
+			// gives a clear error early; chk_tax_rate_kind catches a
+			// concurrent patch of the other flag.
+			var cur Rate
+			err := scanRate(pool.QueryRow(r.Context(), `SELECT `+rateColumns+` FROM tax_rates WHERE id=$1 AND owner_id=$2`, id, ownerID), &cur)
+			if err != nil {
+				writeRateErr(w, err)
+				return
+			}
+			compound, withholding := cur.Compound, cur.Withholding
+			if in.Compound != nil {
+				compound = *in.Compound
+			}
+			if in.Withholding != nil {
+				withholding = *in.Withholding
+			}
+			if compound && withholding {
+				writeCompoundWithholding(w)
+				return
+			}
+		}
+
+		if len(sets) == 0 {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "no fields to update")
+			return
+		}
+
+		sets = append(sets, "updated_at=now()")
+		args = append(args, id, ownerID)
+
+		query := fmt.Sprintf(`UPDATE tax_rates SET %s WHERE id=$%d AND owner_id=$%d RETURNING `+rateColumns, strings.Join(sets, ", "), idx, idx+1)
+
+		var t Rate
+		err = scanRate(pool.QueryRow(r.Context(), query, args...), &t)
+		if err != nil && utils.IsUniqueViolationErr(err) {
+			utils.WriteErr(w, http.StatusConflict, "conflict", "a tax with the same name already exists")
+			return
+		}
+		if utils.IsCheckViolationErr(err, "chk_tax_rate_kind") {
+			writeCompoundWithholding(w)
+			return
+		}
+		if err != nil {
+			writeRateErr(w, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, t)
+	}
+}
+
+// DeleteRate removes a tax definition. Computed quotes keep their snapshot,
+// which stands in for the definition when they are revised or duplicated.
+// A tax still referenced by a draft quote or a template cannot be deleted,
+// as their next recalculation would fail.
+func DeleteRate(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, ok := auth.RequireUserID(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(strings.TrimSpace(chi.URLParam(r, "id")))
+		if err != nil {
+			utils.WriteErr(w, http.StatusBadRequest, "validation_error", "invalid uuid")
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		var found bool
+		err = tx.QueryRow(r.Context(), `SELECT true FROM tax_rates WHERE id=$1 AND owner_id=$2 FOR UPDATE`, id, ownerID).Scan(&found)
+		if err != nil {
+			writeRateErr(w, err)
+			return
+		}
+
+		// Referenced by the quote or template, or by one of its items.
+		var drafts, templates int
+		err = tx.QueryRow(r.Context(), `
+			SELECT
+				(SELECT COUNT(*) FROM quotes q WHERE q.owner_id=$2 AND q.status='draft' AND ($1 = ANY(q.tax_ids)
+					OR EXISTS (SELECT 1 FROM jsonb_array_elements(q.items) it WHERE it->'tax_ids' ? $1::text))),
+				(SELECT COUNT(*) FROM quote_templates t WHERE t.owner_id=$2 AND ($1 = ANY(t.tax_ids)
+					OR EXISTS (SELECT 1 FROM jsonb_array_elements(t.items) it WHERE it->'tax_ids' ? $1::text)))`,
+			id, ownerID).Scan(&drafts, &templates)
+		if err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+		if drafts > 0 || templates > 0 {
+			utils.WriteErr(w, http.StatusConflict, "tax_in_use",
+				fmt.Sprintf("tax is used by %d draft quote(s) and %d template(s); remove it from them first", drafts, templates))
+			return
+		}
+
+		if _, err := tx.Exec(r.Context(), `DELETE FROM tax_rates WHERE id=$1`, id); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeRateErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErr(w, http.StatusNotFound, "not_found", "tax not found")
+		return
+	}
+	utils.WriteErr(w, http.StatusInternalServerError, "db_error", err.Error())
+}
+
+// writeCompoundWithholding rejects a tax that is both compound and a
+// withholding: compound taxes stack on the additive taxes of a line, which a
+// withholding is not part of.
+func writeCompoundWithholding(w http.ResponseWriter) {
+	utils.WriteErr(w, http.StatusUnprocessableEntity, "validation_error", "a tax cannot be both compound and withholding")
+}
@@ -0,0 +1,40 @@
+package taxes
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rateColumns lists the columns read into a Rate, in scanRate order.
+const rateColumns = `id, name, rate, compound, withholding, created_at, updated_at`
+
+func scanRate(row pgx.Row, t *Rate) error {
+	return row.Scan(&t.ID, &t.Name, &t.Rate, &t.Compound, &t.Withholding, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// GetMany returns the owner's taxes with the given ids, keyed by id. Unknown
+// ids and other owners' taxes are simply absent from the map.
+func GetMany(ctx context.Context, pool *pgxpool.Pool, ownerID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]Rate, error) {
+	out := map[uuid.UUID]Rate{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	rows, err := pool.Query(ctx, `SELECT `+rateColumns+` FROM tax_rates WHERE owner_id=$1 AND id = ANY($2)`, ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Rate
+		if err := scanRate(rows, &t); err != nil {
+			return nil, err
+		}
+		out[t.ID] = t
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,36 @@
+package taxes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roblesvargas97/estimago/internal/money"
+)
+
+// Rate is a named tax an account applies to quotes. Rate is a percentage.
+// A compound tax is computed on the base plus the simple taxes of the same
+// line (e.g. Quebec QST on top of GST); a withholding is subtracted instead
+// of added (e.g. Mexican ISR and IVA retentions).
+type Rate struct {
+	ID          uuid.UUID     `json:"id"`
+	Name        string        `json:"name"`
+	Rate        money.Decimal `json:"rate"`
+	Compound    bool          `json:"compound"`
+	Withholding bool          `json:"withholding"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+type CreateRateIn struct {
+	Name        string        `json:"name"`
+	Rate        money.Decimal `json:"rate"`
+	Compound    bool          `json:"compound"`
+	Withholding bool          `json:"withholding"`
+}
+
+type UpdateRateIn struct {
+	Name        *string        `json:"name"`
+	Rate        *money.Decimal `json:"rate"`
+	Compound    *bool          `json:"compound"`
+	Withholding *bool          `json:"withholding"`
+}
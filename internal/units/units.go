@@ -0,0 +1,141 @@
+// Package units is the registry of units quote lines and catalog items can
+// be measured in, and the conversions between units of the same kind.
+package units
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Kind groups units that convert into each other.
+type Kind string
+
+const (
+	Count  Kind = "count"
+	Time   Kind = "time"
+	Length Kind = "length"
+	Area   Kind = "area"
+	Volume Kind = "volume"
+	Mass   Kind = "mass"
+)
+
+// Unit is a registered unit. Factor is its size in the base unit of its
+// kind (m, m², m³, kg); count and time units do not convert.
+type Unit struct {
+	Code   string
+	Kind   Kind
+	Factor *big.Rat
+}
+
+type def struct {
+	code    string
+	kind    Kind
+	factor  string // exact, in the base unit of the kind
+	aliases []string
+}
+
+// Conversion factors are the exact international definitions
+// (1 ft = 0.3048 m, 1 lb = 0.45359237 kg, 1 US gal = 3.785411784 L).
+var defs = []def{
+	{"pc", Count, "", []string{"pcs", "piece", "pieces", "ea", "each", "unit", "units", "u", "pz", "pza", "pieza", "piezas"}},
+	{"set", Count, "", []string{"sets", "kit", "juego"}},
+	{"lot", Count, "", []string{"lots", "lote"}},
+	{"service", Count, "", []string{"svc", "servicio"}},
+	{"pair", Count, "", []string{"pairs", "pr", "par", "pares"}},
+	{"box", Count, "", []string{"boxes", "bx", "caja", "cajas"}},
+	{"bag", Count, "", []string{"bags", "sack", "sacks", "bolsa", "bolsas", "saco", "sacos", "bulto", "bultos"}},
+	{"roll", Count, "", []string{"rolls", "rollo", "rollos"}},
+	{"sheet", Count, "", []string{"sheets", "panel", "panels", "hoja", "hojas", "lamina", "lámina", "laminas", "láminas"}},
+	{"pallet", Count, "", []string{"pallets", "tarima", "tarimas"}},
+	{"bucket", Count, "", []string{"buckets", "pail", "pails", "cubeta", "cubetas"}},
+	{"can", Count, "", []string{"cans", "lata", "latas"}},
+	{"tube", Count, "", []string{"tubes", "tubo", "tubos"}},
+
+	{"h", Time, "", []string{"hr", "hrs", "hour", "hours", "hora", "horas"}},
+	{"day", Time, "", []string{"d", "days", "dia", "día", "dias", "días"}},
+
+	{"mm", Length, "0.001", nil},
+	{"cm", Length, "0.01", nil},
+	{"m", Length, "1", []string{"meter", "meters", "metre", "metres", "metro", "metros"}},
+	{"km", Length, "1000", nil},
+	{"in", Length, "0.0254", []string{"inch", "inches", "pulgada", "pulgadas"}},
+	{"ft", Length, "0.3048", []string{"foot", "feet", "pie", "pies"}},
+	{"yd", Length, "0.9144", []string{"yard", "yards"}},
+
+	{"cm2", Area, "0.0001", []string{"cm²"}},
+	{"m2", Area, "1", []string{"m²", "sqm", "sq m", "sq.m"}},
+	{"in2", Area, "0.00064516", []string{"in²", "sq in"}},
+	{"ft2", Area, "0.09290304", []string{"ft²", "sqft", "sq ft", "sq.ft"}},
+	{"yd2", Area, "0.83612736", []string{"yd²", "sq yd"}},
+
+	{"l", Volume, "0.001", []string{"liter", "liters", "litre", "litres", "litro", "litros"}},
+	{"m3", Volume, "1", []string{"m³", "cbm"}},
+	{"ft3", Volume, "0.028316846592", []string{"ft³", "cu ft"}},
+	{"gal", Volume, "0.003785411784", []string{"gallon", "gallons", "galon", "galón", "galones"}},
+
+	{"g", Mass, "0.001", []string{"gram", "grams", "gramo", "gramos"}},
+	{"kg", Mass, "1", []string{"kilo", "kilos", "kilogram", "kilograms", "kilogramo", "kilogramos"}},
+	{"t", Mass, "1000", []string{"ton", "tonne", "tonelada", "toneladas"}},
+	{"oz", Mass, "0.028349523125", []string{"ounce", "ounces", "onza", "onzas"}},
+	{"lb", Mass, "0.45359237", []string{"lbs", "pound", "pounds", "libra", "libras"}},
+}
+
+var byName = map[string]Unit{}
+
+func init() {
+	for _, d := range defs {
+		u := Unit{Code: d.code, Kind: d.kind}
+		if d.factor != "" {
+			f, ok := new(big.Rat).SetString(d.factor)
+			if !ok {
+				panic("units: bad factor for " + d.code)
+			}
+			u.Factor = f
+		}
+		for _, name := range append([]string{d.code}, d.aliases...) {
+			byName[normName(name)] = u
+		}
+	}
+}
+
+func normName(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// Lookup finds a unit by code or alias, ignoring case and extra spaces.
+func Lookup(name string) (Unit, bool) {
+	u, ok := byName[normName(name)]
+	return u, ok
+}
+
+// Normalize returns the code of a known unit. The empty unit is allowed and
+// stays empty.
+func Normalize(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil
+	}
+	u, ok := Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("unknown unit %q", strings.TrimSpace(name))
+	}
+	return u.Code, nil
+}
+
+// Convertible reports whether values in from can be expressed in to.
+func Convertible(from, to Unit) bool {
+	return from.Kind == to.Kind && from.Factor != nil && to.Factor != nil
+}
+
+// Convert expresses v, measured in from, in to. Units of different kinds do
+// not convert; a unit always converts to itself.
+func Convert(v *big.Rat, from, to Unit) (*big.Rat, error) {
+	if from.Code == to.Code {
+		return new(big.Rat).Set(v), nil
+	}
+	if !Convertible(from, to) {
+		return nil, fmt.Errorf("cannot convert %s to %s", from.Code, to.Code)
+	}
+	out := new(big.Rat).Mul(v, from.Factor)
+	return out.Quo(out, to.Factor), nil
+}
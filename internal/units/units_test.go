@@ -0,0 +1,129 @@
+package units
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"m2", "m2"},
+		{"M²", "m2"},
+		{" SQ   FT ", "ft2"},
+		{"sq.ft", "ft2"},
+		{"Pieza", "pc"},
+		{"each", "pc"},
+		{"hrs", "h"},
+		{"días", "day"},
+		{"Cajas", "box"},
+		{"bulto", "bag"},
+		{"lámina", "sheet"},
+		{"tarimas", "pallet"},
+		{"cubeta", "bucket"},
+		{"galón", "gal"},
+		{"Libras", "lb"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"furlong", "m 2", "sqft2"} {
+		if _, err := Normalize(in); err == nil || !strings.Contains(err.Error(), "unknown unit") {
+			t.Errorf("Normalize(%q) error = %v, want unknown unit", in, err)
+		}
+	}
+}
+
+// Every code and alias must resolve to its own unit; a name listed twice
+// would silently map to whichever def comes last.
+func TestNamesAreUnique(t *testing.T) {
+	seen := map[string]string{}
+	for _, d := range defs {
+		for _, name := range append([]string{d.code}, d.aliases...) {
+			key := normName(name)
+			if prev, ok := seen[key]; ok {
+				t.Errorf("%q is used by both %s and %s", name, prev, d.code)
+			}
+			seen[key] = d.code
+		}
+	}
+}
+
+func TestConvertible(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"m", "ft", true},
+		{"m2", "ft2", true},
+		{"l", "gal", true},
+		{"kg", "lb", true},
+		{"m", "m2", false},
+		{"m3", "kg", false},
+		{"pc", "pc", false},
+		{"pc", "box", false},
+		{"h", "day", false},
+	}
+	for _, tt := range tests {
+		from, _ := Lookup(tt.from)
+		to, _ := Lookup(tt.to)
+		if got := Convertible(from, to); got != tt.want {
+			t.Errorf("Convertible(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		v        string
+		from, to string
+		want     string
+	}{
+		{"1", "ft", "m", "0.3048"},
+		{"1", "m", "ft", "1250/381"},
+		{"1", "yd", "ft", "3"},
+		{"1", "ft2", "in2", "144"},
+		{"1", "gal", "l", "3.785411784"},
+		{"1", "lb", "oz", "16"},
+		{"2.5", "t", "kg", "2500"},
+		{"1500", "mm", "m", "1.5"},
+		{"3", "pc", "pc", "3"},
+		{"8", "h", "h", "8"},
+	}
+	for _, tt := range tests {
+		v, _ := new(big.Rat).SetString(tt.v)
+		want, _ := new(big.Rat).SetString(tt.want)
+		from, _ := Lookup(tt.from)
+		to, _ := Lookup(tt.to)
+
+		got, err := Convert(v, from, to)
+		if err != nil {
+			t.Errorf("Convert(%s %s to %s): %v", tt.v, tt.from, tt.to, err)
+			continue
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("Convert(%s %s to %s) = %s, want %s", tt.v, tt.from, tt.to, got.RatString(), want.RatString())
+		}
+	}
+
+	for _, pair := range [][2]string{{"pc", "box"}, {"m", "kg"}, {"h", "day"}, {"m2", "m"}} {
+		from, _ := Lookup(pair[0])
+		to, _ := Lookup(pair[1])
+		if _, err := Convert(big.NewRat(1, 1), from, to); err == nil {
+			t.Errorf("Convert(%s to %s) succeeded, want error", pair[0], pair[1])
+		}
+	}
+}
@@ -31,3 +31,12 @@ func IsUniqueViolationErr(err error) bool {
 	msg := strings.ToLower(err.Error())
 	return strings.Contains(msg, "duplicate key value violates unique constraint")
 }
+
+// IsCheckViolationErr reports whether err is a PostgreSQL violation of the
+// named CHECK constraint. Like IsUniqueViolationErr it matches the message.
+func IsCheckViolationErr(err error, constraint string) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(err.Error(), `violates check constraint "`+constraint+`"`)
+}
@@ -1,12 +1,17 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 )
 
+// ErrEmptyBody is returned by DecodeJSON for a request without a body.
+// Handlers whose body is optional treat it as an empty input.
+var ErrEmptyBody = errors.New("empty body")
+
 // DecodeJSON - Safely decodes JSON request body into provided struct
 // Purpose:
 //
@@ -42,7 +47,7 @@ import (
 //
 // Behavior:
 //   - Returns an error if:
-//   - The body is empty
+//   - The body is empty (ErrEmptyBody)
 //   - The JSON contains fields not defined in the struct
 //   - The JSON contains multiple top-level objects
 //   - A field type does not match the expected Go type
@@ -52,14 +57,30 @@ func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
 	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 	defer r.Body.Close()
 
-	dec := json.NewDecoder(r.Body)
+	return decodeStrict(r.Body, v)
+}
+
+// DecodeJSONBytes - Decodes an already-read JSON body with DecodeJSON's rules
+// Purpose: Lets handlers that inspect the raw body (e.g. to layer it over defaults) keep strict decoding
+// Advantages:
+//   - Same unknown-field, type and multiple-object checks as DecodeJSON
+//   - Decoding into a pre-filled struct only overwrites the fields present in the body
+//
+// Weaknesses:
+//   - The caller is responsible for bounding the size of b
+func DecodeJSONBytes(b []byte, v any) error {
+	return decodeStrict(bytes.NewReader(b), v)
+}
+
+func decodeStrict(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	if err := dec.Decode(v); err != nil {
 		var ute *json.UnmarshalTypeError
 		switch {
 		case errors.Is(err, io.EOF):
-			return errors.New("empty body")
+			return ErrEmptyBody
 		case errors.As(err, &ute):
 			return errors.New("wrong type for field: " + ute.Field)
 		default:
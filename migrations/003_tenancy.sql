@@ -0,0 +1,13 @@
+-- Per-user tenancy: every client and quote belongs to the user that created it.
+-- Rows created before this migration have no owner and are no longer reachable
+-- through the API.
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE quotes  ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+-- (name, email) is only unique inside a tenant.
+ALTER TABLE clients DROP CONSTRAINT IF EXISTS clients_name_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_clients_owner_name_email ON clients(owner_id, name, email);
+
+CREATE INDEX IF NOT EXISTS idx_clients_owner_created ON clients(owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_quotes_owner_created  ON quotes(owner_id, created_at DESC);
@@ -0,0 +1,33 @@
+-- Immutable quote history: every content change stores a numbered snapshot.
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS quote_revisions (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  quote_id    UUID NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+  number      INT  NOT NULL,
+  snapshot    JSONB NOT NULL,
+  created_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (quote_id, number)
+);
+
+-- Quotes created before this migration start their history at revision 1.
+INSERT INTO quote_revisions (quote_id, number, snapshot, created_by, created_at)
+SELECT id, 1, jsonb_build_object(
+         'client_id',   client_id,
+         'items',       items,
+         'labor_hours', labor_hours,
+         'labor_rate',  labor_rate,
+         'margin_pct',  margin_pct,
+         'tax_pct',     tax_pct::text,
+         'subtotal',    subtotal,
+         'total',       total,
+         'currency',    currency,
+         'notes',       notes
+       ), owner_id, updated_at
+FROM quotes
+WHERE revision = 0
+ON CONFLICT (quote_id, number) DO NOTHING;
+
+UPDATE quotes SET revision = 1 WHERE revision = 0;
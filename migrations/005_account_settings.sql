@@ -0,0 +1,9 @@
+-- Per-account preferences. One row per user, created on first update.
+
+CREATE TABLE IF NOT EXISTS account_settings (
+  owner_id     UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  footer_text  TEXT,
+  logo         BYTEA,                                -- normalized to JPEG on upload
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- Customer responses to shared quotes (public_id links).
+
+ALTER TABLE quotes
+  ADD COLUMN IF NOT EXISTS responded_at   TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS response_ip    TEXT,
+  ADD COLUMN IF NOT EXISTS signature_name TEXT;
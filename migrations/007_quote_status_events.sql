@@ -0,0 +1,17 @@
+-- Audit log of quote status transitions.
+
+CREATE TABLE IF NOT EXISTS quote_status_events (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  quote_id     UUID NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+  from_status  TEXT NOT NULL,
+  to_status    TEXT NOT NULL,
+  actor_type   TEXT NOT NULL,                       -- user, customer or system
+  actor_id     UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_status_events_quote ON quote_status_events(quote_id, created_at);
+
+ALTER TABLE quotes DROP CONSTRAINT IF EXISTS chk_quote_status;
+ALTER TABLE quotes ADD CONSTRAINT chk_quote_status
+  CHECK (status IN ('draft', 'sent', 'accepted', 'rejected', 'expired'));
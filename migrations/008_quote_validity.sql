@@ -0,0 +1,13 @@
+-- Quote validity periods. Sent quotes past valid_until are moved to
+-- 'expired' by the background sweep in cmd/server.
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS valid_until DATE;
+
+ALTER TABLE account_settings
+  ADD COLUMN IF NOT EXISTS default_valid_days INT NOT NULL DEFAULT 30;
+
+ALTER TABLE account_settings DROP CONSTRAINT IF EXISTS chk_default_valid_days;
+ALTER TABLE account_settings ADD CONSTRAINT chk_default_valid_days
+  CHECK (default_valid_days BETWEEN 1 AND 365);
+
+CREATE INDEX IF NOT EXISTS idx_quotes_sent_valid_until ON quotes(valid_until) WHERE status = 'sent';
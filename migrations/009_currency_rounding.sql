@@ -0,0 +1,21 @@
+-- Currency-aware rounding. Totals are rounded to the minor units of the
+-- quote's currency (0 for JPY, 3 for KWD), so money columns no longer have a
+-- fixed scale of 2, and each quote records the rounding mode it used.
+
+ALTER TABLE quotes ALTER COLUMN labor_rate TYPE NUMERIC;
+ALTER TABLE quotes ALTER COLUMN subtotal   TYPE NUMERIC;
+ALTER TABLE quotes ALTER COLUMN total      TYPE NUMERIC;
+
+ALTER TABLE quotes
+  ADD COLUMN IF NOT EXISTS rounding_mode TEXT NOT NULL DEFAULT 'half_up';
+
+ALTER TABLE account_settings
+  ADD COLUMN IF NOT EXISTS rounding_mode TEXT NOT NULL DEFAULT 'half_up';
+
+ALTER TABLE quotes DROP CONSTRAINT IF EXISTS chk_quote_rounding_mode;
+ALTER TABLE quotes ADD CONSTRAINT chk_quote_rounding_mode
+  CHECK (rounding_mode IN ('half_up', 'half_even', 'up'));
+
+ALTER TABLE account_settings DROP CONSTRAINT IF EXISTS chk_rounding_mode;
+ALTER TABLE account_settings ADD CONSTRAINT chk_rounding_mode
+  CHECK (rounding_mode IN ('half_up', 'half_even', 'up'));
@@ -0,0 +1,12 @@
+-- Line-item and quote-level discounts. Line discounts live in the items
+-- JSON; the quote-level discount and the computed breakdown live here:
+-- gross (before discounts) - discount_amount = net, which equals subtotal.
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS discount        JSONB;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS gross           NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS discount_amount NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS net             NUMERIC NOT NULL DEFAULT 0;
+
+-- Quotes saved before discounts existed had none.
+UPDATE quotes SET gross = subtotal, net = subtotal
+WHERE gross = 0 AND net = 0 AND subtotal <> 0;
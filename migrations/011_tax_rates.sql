@@ -0,0 +1,38 @@
+-- Named tax definitions per account (IVA 16%, ISR retention 10%, GST, PST...).
+-- Quotes reference them by id and snapshot the applied definitions in
+-- quotes.taxes, so editing a tax never changes a quote already computed.
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT NOT NULL,
+  rate         NUMERIC(7,4) NOT NULL,              -- percentage
+  compound     BOOLEAN NOT NULL DEFAULT false,     -- applied on base + simple taxes
+  withholding  BOOLEAN NOT NULL DEFAULT false,     -- subtracted from the total
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT chk_tax_rate_range CHECK (rate BETWEEN 0 AND 100),
+  -- compound taxes stack on additive taxes, which a withholding is not
+  CONSTRAINT chk_tax_rate_kind  CHECK (NOT (compound AND withholding))
+);
+
+-- Tables created before the rule: taxes that were both become plain
+-- withholdings, the way the API now asks them to be defined.
+UPDATE tax_rates SET compound = false WHERE compound AND withholding;
+ALTER TABLE tax_rates DROP CONSTRAINT IF EXISTS chk_tax_rate_kind;
+ALTER TABLE tax_rates ADD CONSTRAINT chk_tax_rate_kind
+  CHECK (NOT (compound AND withholding));
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tax_rates_owner_name ON tax_rates(owner_id, lower(name));
+
+-- tax_ids apply to labor and to items without their own tax_ids; taxes is
+-- the computed per-tax breakdown.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS tax_ids UUID[] NOT NULL DEFAULT '{}';
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS taxes   JSONB  NOT NULL DEFAULT '[]';
+
+-- Quotes computed with the single tax_pct get it as their only tax line.
+UPDATE quotes
+SET taxes = jsonb_build_array(jsonb_build_object(
+  'name', 'Tax', 'rate', tax_pct::text, 'compound', false, 'withholding', false,
+  'base', subtotal::text, 'amount', (total - subtotal)::text))
+WHERE tax_pct <> 0 AND taxes = '[]'::jsonb;
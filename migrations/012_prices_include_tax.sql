@@ -0,0 +1,3 @@
+-- Quotes whose unit prices and labor rate already include tax. Taxes are
+-- then back-calculated out of the entered prices instead of added on top.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT false;
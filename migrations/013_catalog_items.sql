@@ -0,0 +1,20 @@
+-- Products and services an account sells. Quote items may reference one by
+-- id; the name, unit and price are copied into the quote's items JSON, so
+-- editing or deleting a catalog item never changes an existing quote.
+
+CREATE TABLE IF NOT EXISTS catalog_items (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  sku          TEXT,
+  kind         TEXT NOT NULL DEFAULT '',
+  name         TEXT NOT NULL,
+  unit         TEXT NOT NULL DEFAULT '',             -- default unit on quote items
+  cost         NUMERIC NOT NULL DEFAULT 0,           -- what the account pays
+  sell_price   NUMERIC NOT NULL DEFAULT 0,           -- default unit_price on quote items
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT chk_catalog_amounts CHECK (cost >= 0 AND sell_price >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_catalog_items_owner_sku ON catalog_items(owner_id, lower(sku)) WHERE sku IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_catalog_items_owner_name ON catalog_items(owner_id, name);
@@ -0,0 +1,17 @@
+-- Internal cost of a quote: qty × unit_cost over its selected items plus all
+-- labor before margin. cost_complete is false when a selected item has no
+-- unit_cost: the cost then understates the real one, so the effective margin
+-- is not reported. Profit and effective margin are measured against the
+-- subtotal (before tax) and never shown to customers.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS cost NUMERIC NOT NULL DEFAULT 0;
+
+-- Quotes costed before labor was counted are complete once recalculated.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS cost_complete BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS gross_profit NUMERIC
+  GENERATED ALWAYS AS (subtotal - cost) STORED;
+
+-- NULL when there is nothing to measure against or the cost is partial.
+ALTER TABLE quotes DROP COLUMN IF EXISTS effective_margin_pct;
+ALTER TABLE quotes ADD COLUMN effective_margin_pct NUMERIC
+  GENERATED ALWAYS AS (CASE WHEN cost_complete AND subtotal > 0 THEN round((subtotal - cost) * 100 / subtotal, 2) END) STORED;
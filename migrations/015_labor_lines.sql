@@ -0,0 +1,7 @@
+-- Labor split by role (electrician, helper, supervisor...), each with its
+-- own hours, rate and optional overtime multiplier. The single
+-- labor_hours × labor_rate pair stays and is added to the lines.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS labor_lines JSONB NOT NULL DEFAULT '[]';
+
+-- Default hourly rate per role name, used by labor lines without a rate.
+ALTER TABLE account_settings ADD COLUMN IF NOT EXISTS labor_rates JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,27 @@
+-- Reusable starting points for quotes (bathroom remodel, standard install).
+-- Content is stored as entered; catalog items and default labor rates are
+-- resolved when a quote is created from the template.
+
+CREATE TABLE IF NOT EXISTS quote_templates (
+  id                  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id            UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name                TEXT NOT NULL,
+  items               JSONB NOT NULL DEFAULT '[]',
+  labor_hours         NUMERIC NOT NULL DEFAULT 0,
+  labor_rate          NUMERIC NOT NULL DEFAULT 0,
+  labor_lines         JSONB NOT NULL DEFAULT '[]',
+  margin_pct          NUMERIC NOT NULL DEFAULT 0,
+  tax_pct             NUMERIC NOT NULL DEFAULT 0,
+  tax_ids             UUID[] NOT NULL DEFAULT '{}',
+  prices_include_tax  BOOLEAN NOT NULL DEFAULT false,
+  discount            JSONB,
+  currency            TEXT NOT NULL,
+  notes               TEXT,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_quote_templates_owner_name ON quote_templates(owner_id, lower(name));
+
+-- The template a quote was created from, if any.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES quote_templates(id) ON DELETE SET NULL;
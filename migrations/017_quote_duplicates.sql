@@ -0,0 +1,2 @@
+-- The quote a draft was duplicated from, for traceability.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS source_quote_id UUID REFERENCES quotes(id) ON DELETE SET NULL;
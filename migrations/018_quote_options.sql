@@ -0,0 +1,7 @@
+-- Items may be grouped in named sections, be optional, or be alternatives
+-- of a group ("good/better/best"); the flags live in the items JSON. The
+-- totals cover the selected configuration only.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS sections JSONB NOT NULL DEFAULT '[]';
+
+-- Indexes of the optional items and alternatives the customer accepted.
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS chosen_options JSONB;
@@ -0,0 +1,6 @@
+-- Quote-level measurements (floor area, wall length...) that item
+-- quantities can be computed from with qty_formula. Templates carry default
+-- measurements so their formulas can be checked.
+
+ALTER TABLE quotes          ADD COLUMN IF NOT EXISTS measurements JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE quote_templates ADD COLUMN IF NOT EXISTS measurements JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+-- Archived clients are kept for their quotes but hidden from the client list.
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_clients_owner_active ON clients(owner_id, created_at DESC) WHERE archived_at IS NULL;
@@ -0,0 +1,41 @@
+-- People and postal addresses of a client. At most one of each is primary.
+-- Quotes can be addressed to a contact and a job-site address; both are
+-- snapshotted into the quote so later edits do not change it.
+
+CREATE TABLE IF NOT EXISTS client_contacts (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  client_id   UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+  name        TEXT NOT NULL,
+  role        TEXT,
+  email       TEXT,
+  phone       TEXT,
+  is_primary  BOOLEAN NOT NULL DEFAULT false,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_contacts_client ON client_contacts(client_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_client_contacts_primary ON client_contacts(client_id) WHERE is_primary;
+
+CREATE TABLE IF NOT EXISTS client_addresses (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  client_id    UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+  label        TEXT,
+  line1        TEXT NOT NULL,
+  line2        TEXT,
+  city         TEXT,
+  state        TEXT,
+  postal_code  TEXT,
+  country      TEXT,
+  is_primary   BOOLEAN NOT NULL DEFAULT false,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_addresses_client ON client_addresses(client_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_client_addresses_primary ON client_addresses(client_id) WHERE is_primary;
+
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS contact_id      UUID REFERENCES client_contacts(id) ON DELETE SET NULL;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS site_address_id UUID REFERENCES client_addresses(id) ON DELETE SET NULL;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS contact         JSONB;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS site_address    JSONB;
@@ -0,0 +1,11 @@
+-- Clients merged into another one. Lookups of a merged id are redirected to
+-- the surviving client.
+
+CREATE TABLE IF NOT EXISTS client_redirects (
+  from_id    UUID PRIMARY KEY,
+  to_id      UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+  owner_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  merged_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_redirects_to ON client_redirects(to_id);
@@ -0,0 +1,23 @@
+-- Account-defined custom fields. Values live in clients.meta under the
+-- field's key and are validated against the definition on write; meta keys
+-- without a definition stay free-form.
+
+CREATE TABLE IF NOT EXISTS client_fields (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  key         TEXT NOT NULL,
+  label       TEXT NOT NULL,
+  type        TEXT NOT NULL,
+  options     TEXT[] NOT NULL DEFAULT '{}',       -- enum values
+  required    BOOLEAN NOT NULL DEFAULT false,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT chk_client_field_type CHECK (type IN ('text', 'number', 'date', 'enum'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_client_fields_owner_key ON client_fields(owner_id, key);
+
+-- Tags are stored lowercased.
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_clients_tags_gin ON clients USING GIN (tags);